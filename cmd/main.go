@@ -10,11 +10,14 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/jackc/pgx/v5"
-	cache "github.com/panzerhomer/banner/internal/cache/redis.go"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/cache"
 	"github.com/panzerhomer/banner/internal/config"
 	"github.com/panzerhomer/banner/internal/handlers"
+	"github.com/panzerhomer/banner/internal/migrate"
 	repository "github.com/panzerhomer/banner/internal/repository/postgres"
+	"github.com/panzerhomer/banner/internal/scheduler"
 	"github.com/panzerhomer/banner/internal/services"
 )
 
@@ -28,62 +31,126 @@ func main() {
 		log.Fatal("loading config failed: ", err)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		authenticator, policy := setupAuth(cfg)
+		runToken(authenticator, policy, os.Args[2:])
+		return
+	}
+
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password, cfg.Database.Name)
 
 	log.Println("[init dsn]", dsn, "\n", cfg.Server)
 
-	var conn *pgx.Conn
-
 	maxAttempts := 10
-	attempt := 1
 
-	for {
-		conn, err = pgx.Connect(ctx, dsn)
-		if err != nil {
-			log.Printf("attempt %d: unable to connect to database: %v\n", attempt, err)
-			if attempt == maxAttempts {
-				log.Fatalf("max attempts reached, unable to connect to database: %v\n", err)
-			}
-			attempt++
-			time.Sleep(2 * time.Second)
-		} else {
-			break
-		}
+	pool := connectPostgres(cfg, dsn, maxAttempts)
+	defer pool.Close()
+
+	log.Println("database connected")
+
+	migrator, err := migrate.New(pool)
+	if err != nil {
+		log.Fatal("loading migrations failed: ", err)
 	}
-	defer conn.Close(ctx)
 
-	if err = conn.Ping(ctx); err != nil {
-		log.Fatalf("can't ping db: %s", err)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(migrator, os.Args[2:])
+		return
 	}
 
-	log.Println("database connected")
+	if err := migrator.Check(ctx); err != nil {
+		log.Fatal("run `migrate up` first: ", err)
+	}
 
-	var redis *cache.Redis
+	authenticator, policy := setupAuth(cfg)
 
-	attempt = 1
+	var (
+		tiers  []cache.BannerCache
+		memory *cache.LRU
+		redis  *cache.Redis
+	)
 
-	for {
-		redis, err = cache.New(cfg)
-		if err != nil {
-			log.Printf("attempt %d: unable to connect to redis: %v\n", attempt, err)
-			if attempt == maxAttempts {
-				log.Fatalf("max attempts reached, unable to connect to redis: %v\n", err)
-			}
-			attempt++
-			time.Sleep(2 * time.Second)
-		} else {
-			break
+	for _, tier := range cfg.Cache.Tiers {
+		switch tier {
+		case "memory":
+			memory = cache.NewLRU(cfg.Cache.Memory.Size, cfg.Cache.Memory.TTL, cache.NewKeyScheme(cfg))
+			tiers = append(tiers, memory)
+		case "redis":
+			redis = connectRedis(cfg, maxAttempts)
+			defer redis.Disconnect()
+
+			log.Println("redis connected")
+
+			tiers = append(tiers, redis)
+		default:
+			log.Fatalf("unknown cache tier: %s", tier)
 		}
 	}
-	defer redis.Disconnect()
 
-	log.Println("redis connected")
+	var bannerCache cache.BannerCache = cache.NewTiered(tiers...)
+
+	busCtx, stopBus := context.WithCancel(ctx)
+	defer stopBus()
 
-	bannerRepo := repository.NewBannerRepo(conn)
-	bannerService := services.NewBannerService(bannerRepo, redis)
+	// In-process banner tiers and the resolved API keys can go out of sync
+	// between replicas.
+	var (
+		bus          *cache.Bus
+		keyEvictions services.KeyEvictions
+	)
+	if cfg.Cache.Bus.Enabled {
+		if redis == nil {
+			log.Fatal("cache bus requires the redis cache tier")
+		}
+
+		var local cache.LocalCache
+		if memory != nil {
+			local = memory
+		}
+
+		bus = cache.NewBus(redis, local, cfg)
+		keyEvictions = bus
+		if memory != nil {
+			bannerCache = cache.NewBroadcast(bannerCache, bus)
+		}
+	}
+
+	bannerRepo := repository.NewBannerRepo(pool)
+	auditService := services.NewAuditService(repository.NewAuditRepo(pool), policy)
+	bannerService := services.NewBannerService(bannerRepo, bannerCache, policy, auditService, cfg)
 	bannerHandler := handlers.NewBannerHandler(bannerService)
-	routes := handlers.Routes(bannerHandler)
+	apiKeyService := services.NewAPIKeyService(repository.NewAPIKeyRepo(pool), policy, auditService, keyEvictions)
+
+	if bus != nil {
+		bus.TrackKeys(apiKeyService)
+		go bus.Subscribe(busCtx)
+
+		log.Println("cache invalidation bus subscribed")
+	}
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	authHandler := handlers.NewAuthHandler(authenticator, apiKeyService, policy)
+	readiness := &handlers.Readiness{}
+	routes := handlers.Routes(bannerHandler, authHandler, apiKeyHandler, handlers.NewAuditHandler(auditService), readiness)
+
+	keysCtx, stopKeys := context.WithCancel(ctx)
+	defer stopKeys()
+
+	keysDone := make(chan struct{})
+	go func() {
+		defer close(keysDone)
+		apiKeyService.Run(keysCtx)
+	}()
+
+	schedulerCtx, stopScheduler := context.WithCancel(ctx)
+	defer stopScheduler()
+
+	if cfg.Scheduler.Enabled {
+		bannerScheduler := scheduler.New(bannerRepo, bannerCache, cfg)
+		go bannerScheduler.Run(schedulerCtx)
+
+		log.Println("scheduler started")
+	}
 
 	httpServer := &http.Server{
 		Addr:           cfg.Server.Address + ":" + cfg.Server.Port,
@@ -101,13 +168,95 @@ func main() {
 
 	log.Println("server is running on " + cfg.Server.Address + ":" + cfg.Server.Port)
 
+	if cfg.Cache.WarmUp.Enabled {
+		if _, err := bannerService.WarmUp(ctx); err != nil {
+			log.Println("cache warm-up failed: ", err)
+		}
+	}
+	readiness.SetReady()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 	<-quit
 
 	log.Print("server is shutting down")
 
+	stopScheduler()
+	stopBus()
+
 	if err := httpServer.Shutdown(ctx); err != nil {
 		log.Fatalf("error occured on server shutting down: %s", err.Error())
 	}
+
+	// Write the last uses of API keys seen before the shutdown.
+	stopKeys()
+	<-keysDone
+}
+
+// setupAuth builds the authenticator and the role policy. Only the
+// commands that issue or check tokens need them, so migrations run
+// without an auth secret.
+func setupAuth(cfg *config.Config) (*auth.Authenticator, *auth.Policy) {
+	authenticator, err := auth.New(cfg)
+	if err != nil {
+		log.Fatal("setting up auth failed: ", err)
+	}
+
+	policy, err := auth.NewPolicy(cfg)
+	if err != nil {
+		log.Fatal("loading roles failed: ", err)
+	}
+
+	return authenticator, policy
+}
+
+func connectPostgres(cfg *config.Config, dsn string, maxAttempts int) *pgxpool.Pool {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		log.Fatalf("invalid database config: %s", err)
+	}
+
+	poolConfig.MaxConns = cfg.Database.Pool.MaxConns
+	poolConfig.MinConns = cfg.Database.Pool.MinConns
+	poolConfig.MaxConnLifetime = cfg.Database.Pool.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.Database.Pool.MaxConnIdleTime
+	poolConfig.HealthCheckPeriod = cfg.Database.Pool.HealthCheckPeriod
+
+	attempt := 1
+
+	for {
+		pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+		if err == nil {
+			// The pool connects lazily, so ping to surface a dead database here.
+			if err = pool.Ping(ctx); err == nil {
+				return pool
+			}
+			pool.Close()
+		}
+
+		log.Printf("attempt %d: unable to connect to database: %v\n", attempt, err)
+		if attempt == maxAttempts {
+			log.Fatalf("max attempts reached, unable to connect to database: %v\n", err)
+		}
+		attempt++
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func connectRedis(cfg *config.Config, maxAttempts int) *cache.Redis {
+	attempt := 1
+
+	for {
+		redis, err := cache.New(cfg)
+		if err == nil {
+			return redis
+		}
+
+		log.Printf("attempt %d: unable to connect to redis: %v\n", attempt, err)
+		if attempt == maxAttempts {
+			log.Fatalf("max attempts reached, unable to connect to redis: %v\n", err)
+		}
+		attempt++
+		time.Sleep(2 * time.Second)
+	}
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/panzerhomer/banner/internal/migrate"
+)
+
+const migrateUsage = "usage: migrate up|down|status|force <version>"
+
+func runMigrate(migrator *migrate.Migrator, args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatal("migrate up failed: ", err)
+		}
+		log.Printf("applied %d migrations, schema is at version %d", applied, migrator.Latest())
+	case "down":
+		version, err := migrator.Down(ctx)
+		if errors.Is(err, migrate.ErrNoChange) {
+			log.Println("nothing to revert")
+			return
+		}
+		if err != nil {
+			log.Fatal("migrate down failed: ", err)
+		}
+		log.Printf("schema is at version %d", version)
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal("migrate status failed: ", err)
+		}
+		fmt.Printf("current version: %d\nlatest version: %d\n", status.Current, status.Latest)
+		for _, m := range status.Pending {
+			fmt.Printf("pending: %d_%s\n", m.Version, m.Name)
+		}
+	case "force":
+		if len(args) != 2 {
+			log.Fatal(migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			log.Fatal(migrateUsage)
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			log.Fatal("migrate force failed: ", err)
+		}
+		log.Printf("schema forced to version %d", version)
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
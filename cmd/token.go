@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+// runToken issues a signed token, e.g. `token -sub alice -role admin -tenant shop -ttl 1h`.
+func runToken(authenticator *auth.Authenticator, policy *auth.Policy, args []string) {
+	flags := flag.NewFlagSet("token", flag.ExitOnError)
+	subject := flags.String("sub", "", "subject the token is issued to")
+	role := flags.String("role", auth.RoleUser, "role of the subject: user, viewer, editor, publisher, admin or one from config")
+	tenantID := flags.String("tenant", tenant.Default, "tenant whose banners the token gives access to")
+	ttl := flags.Duration("ttl", 0, "lifetime of the token (default from config)")
+	flags.Parse(args)
+
+	if *subject == "" {
+		log.Fatal("usage: token -sub <subject> [-role <role>] [-tenant <tenant>] [-ttl <duration>]")
+	}
+
+	// A token for an unknown role would be refused on every request.
+	if !policy.HasRole(*role) {
+		log.Fatalf("unknown role %q", *role)
+	}
+
+	token, expiresAt, err := authenticator.Issue(*subject, *role, *tenantID, *ttl)
+	if err != nil {
+		log.Fatal("issuing token failed: ", err)
+	}
+
+	fmt.Println(token)
+	log.Printf("token for %s (%s, tenant %s) expires at %s", *subject, *role, *tenantID, expiresAt.Format(time.RFC3339))
+}
@@ -2,7 +2,12 @@ module github.com/panzerhomer/banner
 
 go 1.20
 
-require github.com/ilyakaznacheev/cleanenv v1.5.0
+require (
+	github.com/golang-jwt/jwt/v5 v5.2.3
+	github.com/ilyakaznacheev/cleanenv v1.5.0
+	github.com/pkg/errors v0.9.1
+	golang.org/x/sync v0.7.0
+)
 
 require (
 	github.com/ajg/form v1.5.1 // indirect
@@ -10,7 +15,7 @@ require (
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
-	github.com/pkg/errors v0.9.1 // indirect
+	github.com/jackc/puddle/v2 v2.2.1 // indirect
 	golang.org/x/crypto v0.17.0 // indirect
 	golang.org/x/exp v0.0.0-20240409090435-93d18d7e34b8 // indirect
 	golang.org/x/text v0.14.0 // indirect
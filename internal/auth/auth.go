@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/panzerhomer/banner/internal/config"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrCannotIssue  = errors.New("no signing key configured")
+)
+
+// Claims is the payload of the tokens issued by this service.
+type Claims struct {
+	Role   string `json:"role"`
+	Tenant string `json:"tenant,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Authenticator issues and verifies signed JWTs. HS256 uses a shared secret;
+// RS256 verifies with a public key and only issues if a private key is set.
+type Authenticator struct {
+	method  jwt.SigningMethod
+	signKey any
+	keyFunc jwt.Keyfunc
+	issuer  string
+	ttl     time.Duration
+}
+
+func New(cfg *config.Config) (*Authenticator, error) {
+	a := &Authenticator{
+		issuer: cfg.Auth.Issuer,
+		ttl:    cfg.Auth.TokenTTL,
+	}
+
+	switch cfg.Auth.Algorithm {
+	case "HS256":
+		secret, err := loadSecret(cfg.Auth.Secret, cfg.Auth.SecretFile)
+		if err != nil {
+			return nil, err
+		}
+
+		a.method = jwt.SigningMethodHS256
+		a.signKey = secret
+		a.keyFunc = func(*jwt.Token) (any, error) { return secret, nil }
+	case "RS256":
+		publicKey, err := loadPublicKey(cfg.Auth.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		a.method = jwt.SigningMethodRS256
+		a.keyFunc = func(*jwt.Token) (any, error) { return publicKey, nil }
+
+		if cfg.Auth.PrivateKeyFile != "" {
+			privateKey, err := loadPrivateKey(cfg.Auth.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			a.signKey = privateKey
+		}
+	default:
+		return nil, fmt.Errorf("auth: unsupported algorithm %q", cfg.Auth.Algorithm)
+	}
+
+	return a, nil
+}
+
+// Issue signs a token for subject with the given role in tenant. A zero ttl
+// uses the configured default.
+func (a *Authenticator) Issue(subject string, role string, tenantID string, ttl time.Duration) (string, time.Time, error) {
+	if a.signKey == nil {
+		return "", time.Time{}, ErrCannotIssue
+	}
+	if ttl <= 0 {
+		ttl = a.ttl
+	}
+	if !tenant.Valid(tenantID) {
+		return "", time.Time{}, fmt.Errorf("auth: invalid tenant %q", tenantID)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	claims := Claims{
+		Role:   role,
+		Tenant: tenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    a.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(a.method, claims).SignedString(a.signKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// Verify checks the signature, algorithm, issuer and expiry of a token and
+// returns the principal it was issued to.
+func (a *Authenticator) Verify(token string) (Principal, error) {
+	var claims Claims
+
+	_, err := jwt.ParseWithClaims(token, &claims, a.keyFunc,
+		jwt.WithValidMethods([]string{a.method.Alg()}),
+		jwt.WithIssuer(a.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if claims.Subject == "" || claims.Role == "" {
+		return Principal{}, fmt.Errorf("%w: subject and role are required", ErrInvalidToken)
+	}
+
+	// Tokens issued before tenants existed belong to the default one.
+	if claims.Tenant == "" {
+		claims.Tenant = tenant.Default
+	}
+	if !tenant.Valid(claims.Tenant) {
+		return Principal{}, fmt.Errorf("%w: invalid tenant", ErrInvalidToken)
+	}
+
+	return Principal{
+		Subject:   claims.Subject,
+		Role:      claims.Role,
+		Tenant:    claims.Tenant,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// minSecretLength is the shortest HS256 secret accepted, in bytes.
+const minSecretLength = 32
+
+// placeholderSecrets are values that appear in examples and must never sign
+// real tokens.
+var placeholderSecrets = map[string]bool{
+	"change-me": true,
+	"changeme":  true,
+	"change_me": true,
+	"secret":    true,
+	"default":   true,
+	"password":  true,
+}
+
+// loadSecret returns the HS256 secret, read from path if it is not given
+// directly. Missing, placeholder and short secrets are refused, as anyone
+// who knows the secret can forge tokens.
+func loadSecret(secret string, path string) ([]byte, error) {
+	if secret == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("auth: reading secret: %w", err)
+		}
+		secret = strings.TrimRight(string(data), "\r\n")
+	}
+
+	switch {
+	case secret == "":
+		return nil, errors.New("auth: HS256 requires a secret, set AUTH_SECRET or AUTH_SECRET_FILE")
+	case placeholderSecrets[strings.ToLower(secret)]:
+		return nil, errors.New("auth: the HS256 secret is a placeholder, set AUTH_SECRET or AUTH_SECRET_FILE")
+	case len(secret) < minSecretLength:
+		return nil, fmt.Errorf("auth: the HS256 secret must be at least %d bytes", minSecretLength)
+	}
+
+	return []byte(secret), nil
+}
+
+func loadPublicKey(path string) (*rsa.PublicKey, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: reading public key: %w", err)
+	}
+
+	key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+	if err != nil {
+		return nil, fmt.Errorf("auth: parsing public key: %w", err)
+	}
+
+	return key, nil
+}
+
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: reading private key: %w", err)
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, fmt.Errorf("auth: parsing private key: %w", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/config"
+)
+
+func TestNewRefusesWeakSecrets(t *testing.T) {
+	strong := strings.Repeat("k", minSecretLength)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "secret")
+	if err := os.WriteFile(file, []byte(strong+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	weakFile := filepath.Join(dir, "weak")
+	if err := os.WriteFile(weakFile, []byte("change-me\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		file    string
+		wantErr bool
+	}{
+		{"missing", "", "", true},
+		{"placeholder", "change-me", "", true},
+		{"placeholder in capitals", "CHANGEME", "", true},
+		{"short", "0123456789", "", true},
+		{"strong", strong, "", false},
+		{"from file", "", file, false},
+		{"placeholder from file", "", weakFile, true},
+		{"unreadable file", "", filepath.Join(dir, "missing"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Auth.Algorithm = "HS256"
+			cfg.Auth.Issuer = "test"
+			cfg.Auth.Secret = tt.secret
+			cfg.Auth.SecretFile = tt.file
+
+			_, err := New(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSecretFromFileSignsTokens(t *testing.T) {
+	secret := strings.Repeat("s", minSecretLength)
+	file := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(file, []byte(secret+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Auth.Algorithm = "HS256"
+	cfg.Auth.Issuer = "test"
+	cfg.Auth.SecretFile = file
+	fromFile, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	cfg.Auth.SecretFile = ""
+	cfg.Auth.Secret = secret
+	fromEnv, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// The trailing newline of the file is not part of the secret.
+	token, _, err := fromFile.Issue("alice", RoleAdmin, "default", time.Minute)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if _, err := fromEnv.Verify(token); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+// Principal is the authenticated caller of a request. Scopes, if any, limit
+// the permissions of its role further, as done for API keys. A principal
+// only ever sees the banners of its Tenant.
+type Principal struct {
+	Subject   string
+	Role      string
+	Tenant    string
+	Scopes    []Permission
+	ExpiresAt time.Time
+}
+
+func (p Principal) inScope(permission Permission) bool {
+	if len(p.Scopes) == 0 {
+		return true
+	}
+	for _, scope := range p.Scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal also scopes ctx to the tenant of p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return tenant.With(context.WithValue(ctx, principalKey{}, p), p.Tenant)
+}
+
+// FromContext returns the principal the request was authenticated as.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
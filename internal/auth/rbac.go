@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/panzerhomer/banner/internal/config"
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+type Permission string
+
+const (
+	// PermReadBanners lists banners and their revisions.
+	PermReadBanners Permission = "banners:read"
+	// PermReadInactive also serves inactive and out-of-window banners on the
+	// user banner endpoint.
+	PermReadInactive Permission = "banners:read_inactive"
+	// PermWriteBanners creates, edits, deletes banners and submits drafts.
+	PermWriteBanners Permission = "banners:write"
+	// PermPublishBanners approves and rejects revisions and rolls back.
+	PermPublishBanners Permission = "banners:publish"
+	// PermReadUserBanner reads banners on the user banner endpoint.
+	PermReadUserBanner Permission = "user_banner:read"
+	// PermManageCache reads cache statistics and warms the cache up.
+	PermManageCache Permission = "cache:manage"
+	// PermManageKeys creates, rotates and revokes API keys.
+	PermManageKeys Permission = "keys:manage"
+	// PermReadAudit reads the audit log.
+	PermReadAudit Permission = "audit:read"
+)
+
+// Valid reports whether p is one of the permissions above.
+func (p Permission) Valid() bool {
+	switch p {
+	case PermReadBanners, PermReadInactive, PermWriteBanners, PermPublishBanners, PermReadUserBanner, PermManageCache, PermManageKeys, PermReadAudit:
+		return true
+	}
+	return false
+}
+
+const (
+	RoleViewer    = "viewer"
+	RoleEditor    = "editor"
+	RolePublisher = "publisher"
+)
+
+// defaultRoles apply unless the config redefines a role of the same name.
+var defaultRoles = map[string][]config.Grant{
+	RoleUser: {
+		{Permission: string(PermReadUserBanner)},
+	},
+	RoleViewer: {
+		{Permission: string(PermReadUserBanner)},
+		{Permission: string(PermReadBanners)},
+	},
+	RoleEditor: {
+		{Permission: string(PermReadUserBanner)},
+		{Permission: string(PermReadBanners)},
+		{Permission: string(PermReadInactive)},
+		{Permission: string(PermWriteBanners)},
+	},
+	RolePublisher: {
+		{Permission: string(PermReadUserBanner)},
+		{Permission: string(PermReadBanners)},
+		{Permission: string(PermReadInactive)},
+		{Permission: string(PermWriteBanners)},
+		{Permission: string(PermPublishBanners)},
+	},
+	RoleAdmin: {
+		{Permission: string(PermReadUserBanner)},
+		{Permission: string(PermReadBanners)},
+		{Permission: string(PermReadInactive)},
+		{Permission: string(PermWriteBanners)},
+		{Permission: string(PermPublishBanners)},
+		{Permission: string(PermManageCache)},
+		{Permission: string(PermManageKeys)},
+		{Permission: string(PermReadAudit)},
+	},
+}
+
+// featureRange is an inclusive range of feature IDs.
+type featureRange struct {
+	from, to int64
+}
+
+// grant is a permission limited to some features, or to none if features
+// is empty.
+type grant struct {
+	features []featureRange
+}
+
+func (g grant) covers(featureID int64) bool {
+	if len(g.features) == 0 {
+		return true
+	}
+	for _, r := range g.features {
+		if featureID >= r.from && featureID <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy maps roles to the permissions they grant.
+type Policy struct {
+	roles map[string]map[Permission][]grant
+}
+
+func NewPolicy(cfg *config.Config) (*Policy, error) {
+	definitions := make(map[string][]config.Grant, len(defaultRoles))
+	for role, grants := range defaultRoles {
+		definitions[role] = grants
+	}
+	for role, grants := range cfg.RBAC.Roles {
+		definitions[role] = grants
+	}
+
+	p := &Policy{roles: make(map[string]map[Permission][]grant, len(definitions))}
+	for role, grants := range definitions {
+		permissions := make(map[Permission][]grant, len(grants))
+		for _, g := range grants {
+			features, err := parseFeatureRanges(g.Features)
+			if err != nil {
+				return nil, fmt.Errorf("rbac: role %s: %w", role, err)
+			}
+			permission := Permission(g.Permission)
+			if !permission.Valid() {
+				return nil, fmt.Errorf("rbac: role %s: unknown permission %q", role, g.Permission)
+			}
+			permissions[permission] = append(permissions[permission], grant{features: features})
+		}
+		p.roles[role] = permissions
+	}
+
+	return p, nil
+}
+
+// Allows reports whether the principal has permission for at least some
+// features.
+func (p *Policy) Allows(principal Principal, permission Permission) bool {
+	return principal.inScope(permission) && len(p.roles[principal.Role][permission]) > 0
+}
+
+// AllowsFeature reports whether the principal has permission for the given
+// feature.
+func (p *Policy) AllowsFeature(principal Principal, permission Permission, featureID int64) bool {
+	if !principal.inScope(permission) {
+		return false
+	}
+	for _, g := range p.roles[principal.Role][permission] {
+		if g.covers(featureID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize checks that the principal of ctx has permission for the given
+// feature, and returns domain.ErrAccessDenied otherwise.
+func (p *Policy) Authorize(ctx context.Context, permission Permission, featureID int64) error {
+	principal, ok := FromContext(ctx)
+	if !ok || !p.AllowsFeature(principal, permission, featureID) {
+		return domain.ErrAccessDenied
+	}
+	return nil
+}
+
+// CanDelegate reports whether the principal may hand out role limited to
+// scopes, as done when issuing an API key: it must itself hold every
+// permission the holder would get, for at least the same features.
+func (p *Policy) CanDelegate(principal Principal, role string, scopes []Permission) bool {
+	delegate := Principal{Role: role, Scopes: scopes}
+	for permission, grants := range p.roles[role] {
+		if !delegate.inScope(permission) {
+			continue
+		}
+		if !principal.inScope(permission) {
+			return false
+		}
+
+		held := p.roles[principal.Role][permission]
+		for _, g := range grants {
+			if !coveredBy(g, held) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// coveredBy reports whether the features of want are all covered by the
+// grants held.
+func coveredBy(want grant, held []grant) bool {
+	var ranges []featureRange
+	for _, g := range held {
+		if len(g.features) == 0 {
+			return true
+		}
+		ranges = append(ranges, g.features...)
+	}
+	if len(want.features) == 0 {
+		return false
+	}
+
+	for _, r := range want.features {
+		if !rangesCover(ranges, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// rangesCover reports whether the union of ranges contains want.
+func rangesCover(ranges []featureRange, want featureRange) bool {
+	next := want.from
+	for {
+		reach, found := int64(0), false
+		for _, r := range ranges {
+			if r.from <= next && r.to >= next && (!found || r.to > reach) {
+				reach, found = r.to, true
+			}
+		}
+		if !found {
+			return false
+		}
+		if reach >= want.to {
+			return true
+		}
+		next = reach + 1
+	}
+}
+
+// AuthorizedFeatures returns the features the principal of ctx has
+// permission for: all of them, or the listed ranges. No ranges and false
+// mean none.
+func (p *Policy) AuthorizedFeatures(ctx context.Context, permission Permission) ([]domain.FeatureRange, bool) {
+	principal, ok := FromContext(ctx)
+	if !ok || !principal.inScope(permission) {
+		return nil, false
+	}
+
+	var ranges []domain.FeatureRange
+	for _, g := range p.roles[principal.Role][permission] {
+		if len(g.features) == 0 {
+			return nil, true
+		}
+		for _, r := range g.features {
+			ranges = append(ranges, domain.FeatureRange{From: r.from, To: r.to})
+		}
+	}
+	return ranges, false
+}
+
+// HasRole reports whether role is defined.
+func (p *Policy) HasRole(role string) bool {
+	_, ok := p.roles[role]
+	return ok
+}
+
+// parseFeatureRanges reads ranges written as "10-20" or a single "7".
+func parseFeatureRanges(values []string) ([]featureRange, error) {
+	ranges := make([]featureRange, 0, len(values))
+	for _, value := range values {
+		fromValue, toValue, isRange := strings.Cut(strings.TrimSpace(value), "-")
+		if !isRange {
+			toValue = fromValue
+		}
+
+		from, err := strconv.ParseInt(strings.TrimSpace(fromValue), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid feature range %q", value)
+		}
+		to, err := strconv.ParseInt(strings.TrimSpace(toValue), 10, 64)
+		if err != nil || to < from {
+			return nil, fmt.Errorf("invalid feature range %q", value)
+		}
+
+		ranges = append(ranges, featureRange{from: from, to: to})
+	}
+	return ranges, nil
+}
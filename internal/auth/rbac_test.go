@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/panzerhomer/banner/internal/config"
+)
+
+func TestNewPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		grants  []config.Grant
+		wantErr bool
+	}{
+		{"known permission", []config.Grant{{Permission: string(PermReadBanners), Features: []string{"10-20"}}}, false},
+		{"unknown permission", []config.Grant{{Permission: "banners:raed"}}, true},
+		{"empty permission", []config.Grant{{Permission: ""}}, true},
+		{"bad feature range", []config.Grant{{Permission: string(PermReadBanners), Features: []string{"20-10"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.RBAC.Roles = map[string][]config.Grant{"custom": tt.grants}
+
+			policy, err := NewPolicy(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPolicy() error = %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && !policy.HasRole("custom") {
+				t.Error("HasRole(custom) = false")
+			}
+		})
+	}
+}
+
+func TestPolicyHasRole(t *testing.T) {
+	policy, err := NewPolicy(&config.Config{})
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+
+	for _, role := range []string{RoleUser, RoleViewer, RoleEditor, RolePublisher, RoleAdmin} {
+		if !policy.HasRole(role) {
+			t.Errorf("HasRole(%q) = false, want true", role)
+		}
+	}
+	if policy.HasRole("root") {
+		t.Error(`HasRole("root") = true, want false`)
+	}
+}
+
+func TestPolicyCanDelegate(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.RBAC.Roles = map[string][]config.Grant{
+		"promo": {
+			{Permission: string(PermManageKeys)},
+			{Permission: string(PermReadUserBanner), Features: []string{"10-20", "21-30"}},
+			{Permission: string(PermReadBanners), Features: []string{"10-20"}},
+		},
+		"promo-reader": {
+			{Permission: string(PermReadUserBanner), Features: []string{"12-28"}},
+		},
+		"wide-reader": {
+			{Permission: string(PermReadUserBanner), Features: []string{"5-15"}},
+		},
+	}
+	policy, err := NewPolicy(cfg)
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+
+	promo := Principal{Role: "promo"}
+	tests := []struct {
+		name      string
+		principal Principal
+		role      string
+		scopes    []Permission
+		want      bool
+	}{
+		{"admin hands out admin", Principal{Role: RoleAdmin}, RoleAdmin, nil, true},
+		{"role above the caller", promo, RoleAdmin, nil, false},
+		{"unrestricted grant for a limited caller", promo, RoleUser, nil, false},
+		{"scoped to a permission held for fewer features", promo, RoleViewer, []Permission{PermReadBanners}, false},
+		{"ranges covered by several grants", promo, "promo-reader", nil, true},
+		{"range outside the grants", promo, "wide-reader", nil, false},
+		{"caller limited by its own scopes", Principal{Role: RoleAdmin, Scopes: []Permission{PermManageKeys}}, RoleUser, nil, false},
+		{"scopes naming a permission of neither", promo, "promo-reader", []Permission{PermReadUserBanner, PermReadAudit}, true},
+		{"its own role", promo, "promo", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.CanDelegate(tt.principal, tt.role, tt.scopes); got != tt.want {
+				t.Errorf("CanDelegate() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
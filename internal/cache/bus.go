@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// Invalidation is an eviction broadcast to every replica. Generation is
+// taken from a counter in Redis that grows with every published event, so a
+// subscriber can tell that it missed some. An event with a KeyID evicts that
+// API key instead of a banner.
+type Invalidation struct {
+	Generation int64   `json:"generation"`
+	Origin     string  `json:"origin"`
+	Tenant     string  `json:"tenant"`
+	FeatureID  int64   `json:"feature_id"`
+	TagIds     []int64 `json:"tag_ids"`
+	KeyID      int64   `json:"key_id,omitempty"`
+}
+
+// LocalCache is an in-process cache tier kept coherent by the bus.
+type LocalCache interface {
+	DeleteBanner(tenantID string, tagIDs []int64, featureID int64) error
+	Purge()
+}
+
+// LocalKeys is an in-process cache of resolved API keys kept coherent by the
+// bus, so a revoked or rotated key stops working on every replica at once.
+type LocalKeys interface {
+	ForgetAPIKey(id int64)
+	PurgeAPIKeys()
+}
+
+// Bus publishes cache evictions over Redis pub/sub and applies the ones of
+// other replicas to the local cache. Whenever it can not be sure it has seen
+// every event (a generation gap, a reconnect) it purges the local cache.
+type Bus struct {
+	client        redis.UniversalClient
+	local         LocalCache
+	keys          LocalKeys
+	channel       string
+	generationKey string
+	origin        string
+	resync        time.Duration
+
+	mu         sync.Mutex
+	generation int64
+}
+
+// NewBus creates a bus for the local cache tier, which is nil if there is
+// none and only API keys are kept coherent.
+func NewBus(r *Redis, local LocalCache, cfg *config.Config) *Bus {
+	hostname, _ := os.Hostname()
+	channel := cfg.Cache.KeyPrefix + ":invalidations"
+
+	return &Bus{
+		client:        r.client,
+		local:         local,
+		channel:       channel,
+		generationKey: channel + ":generation",
+		origin:        fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		resync:        cfg.Cache.Bus.ResyncInterval,
+	}
+}
+
+// TrackKeys makes the bus evict API keys from keys. It must be called
+// before Subscribe.
+func (b *Bus) TrackKeys(keys LocalKeys) {
+	b.keys = keys
+}
+
+func (b *Bus) Publish(tenantID string, tagIDs []int64, featureID int64) error {
+	return b.publish(Invalidation{Tenant: tenantID, FeatureID: featureID, TagIds: tagIDs})
+}
+
+// PublishAPIKey tells the other replicas to forget a revoked or rotated key.
+func (b *Bus) PublishAPIKey(id int64) error {
+	return b.publish(Invalidation{KeyID: id})
+}
+
+func (b *Bus) publish(event Invalidation) error {
+	generation, err := b.client.Incr(ctx, b.generationKey).Result()
+	if err != nil {
+		return fmt.Errorf("cache bus: bump generation: %w", err)
+	}
+
+	event.Generation = generation
+	event.Origin = b.origin
+	payload, _ := json.Marshal(event)
+
+	if err := b.client.Publish(ctx, b.channel, payload).Err(); err != nil {
+		return fmt.Errorf("cache bus: publish: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe applies invalidations until ctx is cancelled. go-redis
+// re-subscribes after a dropped connection by itself; every (re)subscription
+// and every quiet resync interval compares the local generation with the one
+// in Redis to detect lost messages.
+func (b *Bus) Subscribe(ctx context.Context) {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.ReceiveTimeout(ctx, b.resync)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			var netErr interface{ Timeout() bool }
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				log.Println("cache bus: receive failed: ", err)
+
+				retry := time.NewTimer(time.Second)
+				select {
+				case <-ctx.Done():
+					retry.Stop()
+					return
+				case <-retry.C:
+				}
+			}
+
+			b.sync()
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			b.sync()
+		case *redis.Message:
+			b.apply(msg.Payload)
+		}
+	}
+}
+
+func (b *Bus) sync() {
+	generation, err := b.client.Get(ctx, b.generationKey).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.Println("cache bus: reading generation failed: ", err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		b.purge()
+		b.generation = generation
+	}
+}
+
+func (b *Bus) purge() {
+	if b.local != nil {
+		b.local.Purge()
+	}
+	if b.keys != nil {
+		b.keys.PurgeAPIKeys()
+	}
+}
+
+func (b *Bus) apply(payload string) {
+	var event Invalidation
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Println("cache bus: malformed invalidation: ", err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case event.Generation > b.generation+1:
+		b.purge()
+	case event.Origin == b.origin:
+	case event.KeyID != 0:
+		if b.keys != nil {
+			b.keys.ForgetAPIKey(event.KeyID)
+		}
+	case b.local != nil:
+		b.local.DeleteBanner(event.Tenant, event.TagIds, event.FeatureID)
+	}
+
+	if event.Generation > b.generation {
+		b.generation = event.Generation
+	}
+}
+
+// Broadcast wraps a cache so that every eviction is also published on the
+// bus and reaches the in-process tiers of the other replicas.
+type Broadcast struct {
+	BannerCache
+	bus *Bus
+}
+
+func NewBroadcast(inner BannerCache, bus *Bus) *Broadcast {
+	return &Broadcast{BannerCache: inner, bus: bus}
+}
+
+func (b *Broadcast) DeleteBanner(tenantID string, tagIDs []int64, featureID int64) error {
+	err := b.BannerCache.DeleteBanner(tenantID, tagIDs, featureID)
+
+	return errors.Join(err, b.bus.Publish(tenantID, tagIDs, featureID))
+}
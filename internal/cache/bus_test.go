@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/config"
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+func TestBusApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		event      Invalidation
+		wantShop   bool
+		wantNews   bool
+		generation int64
+	}{
+		{"evicts only the tenant of the event", Invalidation{Generation: 1, Origin: "other", Tenant: "shop", FeatureID: 7, TagIds: []int64{1}}, false, true, 1},
+		{"ignores its own events", Invalidation{Generation: 1, Origin: "self", Tenant: "shop", FeatureID: 7, TagIds: []int64{1}}, true, true, 1},
+		{"purges after a gap", Invalidation{Generation: 3, Origin: "other", Tenant: "shop", FeatureID: 7, TagIds: []int64{1}}, false, false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lru := NewLRU(10, time.Hour, NewKeyScheme(&config.Config{}))
+			for _, tenantID := range []string{"shop", "news"} {
+				banner := domain.Banner{Tenant: tenantID, FeatureID: 7, TagIds: []int64{1}, IsActive: true}
+				entry, _ := NewEntry(banner, time.Now(), time.Hour, time.Hour)
+				if err := lru.SaveBanner(entry); err != nil {
+					t.Fatalf("SaveBanner() error = %v", err)
+				}
+			}
+
+			bus := &Bus{local: lru, origin: "self"}
+			payload, _ := json.Marshal(tt.event)
+			bus.apply(string(payload))
+
+			if _, err := lru.GetBanner("shop", []int64{1}, 7); (err == nil) != tt.wantShop {
+				t.Errorf("shop entry kept = %t, want %t", err == nil, tt.wantShop)
+			}
+			if _, err := lru.GetBanner("news", []int64{1}, 7); (err == nil) != tt.wantNews {
+				t.Errorf("news entry kept = %t, want %t", err == nil, tt.wantNews)
+			}
+			if bus.generation != tt.generation {
+				t.Errorf("generation = %d, want %d", bus.generation, tt.generation)
+			}
+		})
+	}
+}
+
+type fakeKeys struct {
+	forgotten []int64
+	purged    bool
+}
+
+func (k *fakeKeys) ForgetAPIKey(id int64) { k.forgotten = append(k.forgotten, id) }
+func (k *fakeKeys) PurgeAPIKeys()         { k.purged = true }
+
+func TestBusApplyKeyEvictions(t *testing.T) {
+	tests := []struct {
+		name      string
+		event     Invalidation
+		forgotten []int64
+		purged    bool
+	}{
+		{"forgets the key of the event", Invalidation{Generation: 1, Origin: "other", KeyID: 5}, []int64{5}, false},
+		{"ignores its own events", Invalidation{Generation: 1, Origin: "self", KeyID: 5}, nil, false},
+		{"leaves keys alone on banner events", Invalidation{Generation: 1, Origin: "other", Tenant: "shop", FeatureID: 7, TagIds: []int64{1}}, nil, false},
+		{"purges after a gap", Invalidation{Generation: 3, Origin: "other", KeyID: 5}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := &fakeKeys{}
+			// Without an in-process banner tier the bus only tracks keys.
+			bus := &Bus{keys: keys, origin: "self"}
+			payload, _ := json.Marshal(tt.event)
+			bus.apply(string(payload))
+
+			if len(keys.forgotten) != len(tt.forgotten) || (len(tt.forgotten) > 0 && keys.forgotten[0] != tt.forgotten[0]) {
+				t.Errorf("forgotten = %v, want %v", keys.forgotten, tt.forgotten)
+			}
+			if keys.purged != tt.purged {
+				t.Errorf("purged = %t, want %t", keys.purged, tt.purged)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"errors"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+var ErrCacheMiss = errors.New("cache miss")
+
+// BannerCache stores user banners by their tenant, feature and tags.
+// GetBanner returns ErrCacheMiss if there is no usable entry.
+type BannerCache interface {
+	SaveBanner(entry Entry) error
+	GetBanner(tenantID string, tagIDs []int64, featureID int64) (*Entry, error)
+	DeleteBanner(tenantID string, tagIDs []int64, featureID int64) error
+	Stats() []domain.CacheStats
+}
+
+// Entry is a cached user banner. It is fresh until FreshUntil, after that it
+// is a stale copy that may still be served until ExpiresAt. A NotFound entry
+// remembers that there is no banner for the tenant, feature and tags of
+// Banner.
+// ETag is the validator clients revalidate the cached banner with.
+type Entry struct {
+	Banner     domain.Banner `json:"banner"`
+	ETag       string        `json:"etag,omitempty"`
+	NotFound   bool          `json:"not_found,omitempty"`
+	FreshUntil time.Time     `json:"fresh_until"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+}
+
+// NewEntry builds an entry that is fresh for the fresh window and kept for
+// the stale window after that. Neither outlives the banner's activation
+// window; false is returned if the banner must not be cached at all.
+func NewEntry(banner domain.Banner, now time.Time, fresh, stale time.Duration) (Entry, bool) {
+	if !banner.LiveAt(now) {
+		return Entry{}, false
+	}
+
+	entry := Entry{
+		Banner:     banner,
+		ETag:       ETag(banner),
+		FreshUntil: now.Add(fresh),
+		ExpiresAt:  now.Add(fresh + stale),
+	}
+
+	if banner.EndAt != nil {
+		if banner.EndAt.Before(entry.FreshUntil) {
+			entry.FreshUntil = *banner.EndAt
+		}
+		if banner.EndAt.Before(entry.ExpiresAt) {
+			entry.ExpiresAt = *banner.EndAt
+		}
+	}
+
+	return entry, true
+}
+
+// NewNotFoundEntry builds a negative entry, which is never served stale.
+func NewNotFoundEntry(tenantID string, tagIDs []int64, featureID int64, now time.Time, ttl time.Duration) Entry {
+	return Entry{
+		Banner:     domain.Banner{Tenant: tenantID, TagIds: tagIDs, FeatureID: featureID},
+		NotFound:   true,
+		FreshUntil: now.Add(ttl),
+		ExpiresAt:  now.Add(ttl),
+	}
+}
+
+func (e *Entry) Fresh(now time.Time) bool {
+	return now.Before(e.FreshUntil)
+}
+
+// usable reports whether the entry may be served at all at now.
+func (e *Entry) usable(now time.Time) bool {
+	if !now.Before(e.ExpiresAt) {
+		return false
+	}
+
+	return e.NotFound || e.Banner.LiveAt(now)
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+func TestNewEntryEndsWithTheBanner(t *testing.T) {
+	now := time.Now()
+	soon, later := now.Add(30*time.Second), now.Add(30*time.Minute)
+
+	tests := []struct {
+		name       string
+		endAt      *time.Time
+		freshUntil time.Time
+		expiresAt  time.Time
+	}{
+		{"no end", nil, now.Add(time.Minute), now.Add(time.Hour + time.Minute)},
+		{"ends in the fresh window", &soon, soon, soon},
+		{"ends in the stale window", &later, now.Add(time.Minute), later},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			banner := domain.Banner{Tenant: "shop", FeatureID: 1, TagIds: []int64{1}, IsActive: true, EndAt: tt.endAt}
+
+			entry, ok := NewEntry(banner, now, time.Minute, time.Hour)
+			if !ok {
+				t.Fatal("NewEntry() refused a live banner")
+			}
+			if !entry.FreshUntil.Equal(tt.freshUntil) || !entry.ExpiresAt.Equal(tt.expiresAt) {
+				t.Errorf("entry fresh until %s, expires at %s, want %s and %s", entry.FreshUntil, entry.ExpiresAt, tt.freshUntil, tt.expiresAt)
+			}
+		})
+	}
+
+	ended := domain.Banner{Tenant: "shop", FeatureID: 1, TagIds: []int64{1}, IsActive: true, EndAt: &now}
+	if _, ok := NewEntry(ended, now, time.Minute, time.Hour); ok {
+		t.Error("NewEntry() cached a banner that has ended")
+	}
+}
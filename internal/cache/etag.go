@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+// ETag is a strong validator for the banners served by one lookup. It covers
+// the identity, revision and content of each banner, so it changes whenever
+// the response body does.
+func ETag(banners ...domain.Banner) string {
+	h := sha256.New()
+	for _, b := range banners {
+		content, _ := json.Marshal(b.Content)
+		fmt.Fprintf(h, "%d:%d:%s\n", b.BannerID, b.Revision, content)
+	}
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
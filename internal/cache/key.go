@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/panzerhomer/banner/internal/config"
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+// KeyScheme builds cache keys of the form
+//
+//	<prefix>:v<version>:<tenant>:banner:<feature>:<tag>,<tag>,...
+//
+// Tags are sorted and de-duplicated, so their order never matters. The
+// tenant keeps equal feature and tags of different tenants apart. Bumping
+// the version in the config makes every existing entry unreachable at once.
+type KeyScheme struct {
+	prefix  string
+	version int
+}
+
+func NewKeyScheme(cfg *config.Config) KeyScheme {
+	return KeyScheme{prefix: cfg.Cache.KeyPrefix, version: cfg.Cache.KeyVersion}
+}
+
+func (k KeyScheme) Banner(tenantID string, tagIDs []int64, featureID int64) string {
+	var b strings.Builder
+
+	b.WriteString(k.prefix)
+	b.WriteString(":v")
+	b.WriteString(strconv.Itoa(k.version))
+	b.WriteString(":")
+	b.WriteString(tenantID)
+	b.WriteString(":banner:")
+	b.WriteString(strconv.FormatInt(featureID, 10))
+	b.WriteString(":")
+
+	for i, tag := range domain.NormalizeTags(tagIDs) {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(strconv.FormatInt(tag, 10))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/panzerhomer/banner/internal/config"
+)
+
+func TestKeySchemeBanner(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Cache.KeyPrefix = "banner-service"
+	cfg.Cache.KeyVersion = 2
+	keys := NewKeyScheme(cfg)
+
+	if got, want := keys.Banner("shop", []int64{3, 1, 3}, 7), "banner-service:v2:shop:banner:7:1,3"; got != want {
+		t.Errorf("Banner() = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		a, b     string
+		wantSame bool
+	}{
+		{"tag order", keys.Banner("shop", []int64{1, 2}, 7), keys.Banner("shop", []int64{2, 1}, 7), true},
+		{"tenants", keys.Banner("shop", []int64{1, 2}, 7), keys.Banner("news", []int64{1, 2}, 7), false},
+		{"features", keys.Banner("shop", []int64{1, 2}, 7), keys.Banner("shop", []int64{1, 2}, 8), false},
+		{"tags", keys.Banner("shop", []int64{1, 2}, 7), keys.Banner("shop", []int64{12}, 7), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.a == tt.b) != tt.wantSame {
+				t.Errorf("keys %q and %q: same = %t, want %t", tt.a, tt.b, tt.a == tt.b, tt.wantSame)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+// LRU is an in-process, size-bounded banner cache. Entries are kept no longer
+// than the configured TTL; once the cache is full the least recently used
+// entry is evicted.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	keys     KeyScheme
+	items    map[string]*list.Element
+	order    *list.List
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type lruEntry struct {
+	key   string
+	entry Entry
+}
+
+func NewLRU(capacity int, ttl time.Duration, keys KeyScheme) *LRU {
+	return &LRU{
+		capacity: capacity,
+		ttl:      ttl,
+		keys:     keys,
+		items:    make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (c *LRU) SaveBanner(entry Entry) error {
+	now := time.Now()
+	if !entry.usable(now) {
+		return nil
+	}
+
+	if deadline := now.Add(c.ttl); deadline.Before(entry.ExpiresAt) {
+		entry.ExpiresAt = deadline
+		if deadline.Before(entry.FreshUntil) {
+			entry.FreshUntil = deadline
+		}
+	}
+
+	key := c.keys.Banner(entry.Banner.Tenant, entry.Banner.TagIds, entry.Banner.FeatureID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value = &lruEntry{key: key, entry: entry}
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, entry: entry})
+
+	for c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+	}
+
+	return nil
+}
+
+func (c *LRU) GetBanner(tenantID string, tagIDs []int64, featureID int64) (*Entry, error) {
+	key := c.keys.Banner(tenantID, tagIDs, featureID)
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, ErrCacheMiss
+	}
+
+	item := el.Value.(*lruEntry)
+	if !item.entry.usable(now) {
+		c.removeElement(el)
+		c.misses.Add(1)
+		return nil, ErrCacheMiss
+	}
+
+	c.order.MoveToFront(el)
+	c.hits.Add(1)
+
+	entry := item.entry
+	return &entry, nil
+}
+
+func (c *LRU) DeleteBanner(tenantID string, tagIDs []int64, featureID int64) error {
+	key := c.keys.Banner(tenantID, tagIDs, featureID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+
+	return nil
+}
+
+// Purge drops every entry.
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element, c.capacity)
+	c.order.Init()
+}
+
+func (c *LRU) Stats() []domain.CacheStats {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+
+	return []domain.CacheStats{{
+		Tier:    "memory",
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: int64(entries),
+	}}
+}
+
+func (c *LRU) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*lruEntry).key)
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/config"
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+// testEntry is a fresh entry of a live banner of the shop tenant.
+func testEntry(t *testing.T, featureID int64, tagIDs ...int64) Entry {
+	t.Helper()
+
+	banner := domain.Banner{Tenant: "shop", FeatureID: featureID, TagIds: tagIDs, IsActive: true}
+	entry, ok := NewEntry(banner, time.Now(), time.Hour, time.Hour)
+	if !ok {
+		t.Fatal("NewEntry() refused a live banner")
+	}
+	return entry
+}
+
+func save(t *testing.T, c BannerCache, entries ...Entry) {
+	t.Helper()
+
+	for _, entry := range entries {
+		if err := c.SaveBanner(entry); err != nil {
+			t.Fatalf("SaveBanner() error = %v", err)
+		}
+	}
+}
+
+func has(c BannerCache, featureID int64, tagIDs ...int64) bool {
+	_, err := c.GetBanner("shop", tagIDs, featureID)
+	return err == nil
+}
+
+func TestLRUEvictsAtCapacity(t *testing.T) {
+	lru := NewLRU(2, time.Hour, NewKeyScheme(&config.Config{}))
+
+	save(t, lru, testEntry(t, 1, 1), testEntry(t, 2, 1), testEntry(t, 3, 1))
+
+	if has(lru, 1, 1) {
+		t.Error("least recently used entry kept over capacity")
+	}
+	if !has(lru, 2, 1) || !has(lru, 3, 1) {
+		t.Error("recent entries evicted")
+	}
+	if entries := lru.Stats()[0].Entries; entries != 2 {
+		t.Errorf("entries = %d, want 2", entries)
+	}
+}
+
+func TestLRUPromotesOnGet(t *testing.T) {
+	lru := NewLRU(2, time.Hour, NewKeyScheme(&config.Config{}))
+
+	save(t, lru, testEntry(t, 1, 1), testEntry(t, 2, 1))
+	// Reading the older entry makes the other one the least recently used.
+	if !has(lru, 1, 1) {
+		t.Fatal("entry missing before eviction")
+	}
+	save(t, lru, testEntry(t, 3, 1))
+
+	if !has(lru, 1, 1) {
+		t.Error("entry read last was evicted")
+	}
+	if has(lru, 2, 1) {
+		t.Error("least recently used entry kept over capacity")
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+	lru := NewLRU(10, 20*time.Millisecond, NewKeyScheme(&config.Config{}))
+
+	save(t, lru, testEntry(t, 1, 1))
+	if !has(lru, 1, 1) {
+		t.Fatal("entry missing before its TTL")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	if _, err := lru.GetBanner("shop", []int64{1}, 1); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("GetBanner() after the TTL error = %v, want %v", err, ErrCacheMiss)
+	}
+	if entries := lru.Stats()[0].Entries; entries != 0 {
+		t.Errorf("entries = %d, want the expired one dropped", entries)
+	}
+}
+
+func TestLRUCapsEntriesAtTTL(t *testing.T) {
+	lru := NewLRU(10, time.Minute, NewKeyScheme(&config.Config{}))
+
+	save(t, lru, testEntry(t, 1, 1))
+
+	entry, err := lru.GetBanner("shop", []int64{1}, 1)
+	if err != nil {
+		t.Fatalf("GetBanner() error = %v", err)
+	}
+	if limit := time.Now().Add(time.Minute); entry.ExpiresAt.After(limit) || entry.FreshUntil.After(limit) {
+		t.Errorf("entry kept until %s (fresh until %s), want at most the TTL", entry.ExpiresAt, entry.FreshUntil)
+	}
+}
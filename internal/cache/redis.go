@@ -2,11 +2,11 @@ package cache
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
-	"fmt"
-	"log"
+	"net"
 	"strconv"
-	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/panzerhomer/banner/internal/config"
@@ -18,35 +18,85 @@ import (
 var ctx = context.Background()
 
 type Redis struct {
-	client *redis.Client
+	client redis.UniversalClient
+	keys   KeyScheme
+
+	hits   atomic.Int64
+	misses atomic.Int64
 }
 
 func New(cfg *config.Config) (*Redis, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
-	})
-
-	ping, err := client.Ping(context.Background()).Result()
+	client, err := newClient(cfg)
 	if err != nil {
-		log.Println("redis not send PING ("+ping+"), err: ", err)
+		return nil, err
+	}
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, errors.Wrap(err, "redis not send PING")
+	}
+
+	return &Redis{client: client, keys: NewKeyScheme(cfg)}, nil
+}
+
+func newClient(cfg *config.Config) (redis.UniversalClient, error) {
+	rc := cfg.Redis
+
+	opts := &redis.UniversalOptions{
+		Addrs:            rc.Addrs,
+		MasterName:       rc.MasterName,
+		Username:         rc.Username,
+		Password:         rc.Password,
+		SentinelPassword: rc.SentinelPassword,
+		DB:               rc.DB,
+		PoolSize:         rc.PoolSize,
+		MinIdleConns:     rc.MinIdleConns,
+		DialTimeout:      rc.DialTimeout,
+		ReadTimeout:      rc.ReadTimeout,
+		WriteTimeout:     rc.WriteTimeout,
+		PoolTimeout:      rc.PoolTimeout,
+	}
+
+	if len(opts.Addrs) == 0 {
+		opts.Addrs = []string{net.JoinHostPort(rc.Host, strconv.Itoa(rc.Port))}
+	}
+
+	if rc.TLS.Enabled {
+		opts.TLSConfig = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			ServerName:         rc.TLS.ServerName,
+			InsecureSkipVerify: rc.TLS.InsecureSkipVerify,
+		}
 	}
 
-	return &Redis{client: client}, nil
+	switch rc.Mode {
+	case "", "single":
+		return redis.NewClient(opts.Simple()), nil
+	case "sentinel":
+		if opts.MasterName == "" {
+			return nil, errors.New("redis sentinel mode requires master_name")
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case "cluster":
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return nil, errors.Errorf("unknown redis mode: %s", rc.Mode)
+	}
 }
 
 func (r *Redis) Disconnect() {
 	r.client.Close()
 }
 
-func (r *Redis) SaveBanner(bannerID int64, banner domain.Banner) error {
-	ttl := time.Minute * 5
-	tagIds := makeString(banner.TagIds)
-	key := tagIds + fmt.Sprint(banner.FeatureID)
+func (r *Redis) SaveBanner(entry Entry) error {
+	ttl := time.Until(entry.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	key := r.keys.Banner(entry.Banner.Tenant, entry.Banner.TagIds, entry.Banner.FeatureID)
 
-	content, _ := json.Marshal(banner)
-	// fmt.Println("content", string(content))
+	content, _ := json.Marshal(entry)
 	if err := r.client.Set(ctx, key, string(content), ttl).Err(); err != nil {
 		return errors.Wrapf(err, "error when try save in cache with key: %s", key)
 	}
@@ -54,33 +104,50 @@ func (r *Redis) SaveBanner(bannerID int64, banner domain.Banner) error {
 	return nil
 }
 
-func (r *Redis) GetBanner(tagIDs []int64, featureID int64) (*domain.Banner, error) {
-	tagIds := makeString(tagIDs)
-	key := tagIds + fmt.Sprint(featureID)
+func (r *Redis) GetBanner(tenantID string, tagIDs []int64, featureID int64) (*Entry, error) {
+	key := r.keys.Banner(tenantID, tagIDs, featureID)
 
 	var content string
 	if err := r.client.Get(ctx, key).Scan(&content); err != nil {
+		r.misses.Add(1)
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrCacheMiss
+		}
 		return nil, errors.Wrapf(err, "error when try get cache with key: %s", key)
 	}
 
-	// fmt.Println("GetBanner", content, tagIDs, key)
-
-	var bannerCached domain.Banner
-	err := json.Unmarshal([]byte(content), &bannerCached)
+	var entry Entry
+	err := json.Unmarshal([]byte(content), &entry)
 	if err != nil {
+		r.misses.Add(1)
 		return nil, errors.New("banner cached unmarshaling failed")
 	}
 
-	return &bannerCached, nil
+	if !entry.usable(time.Now()) {
+		r.misses.Add(1)
+		r.client.Del(ctx, key)
+		return nil, ErrCacheMiss
+	}
+
+	r.hits.Add(1)
+
+	return &entry, nil
 }
 
-func makeString(nums []int64) string {
-	var strNumbers []string
-	for _, num := range nums {
-		strNumbers = append(strNumbers, strconv.Itoa(int(num)))
+func (r *Redis) DeleteBanner(tenantID string, tagIDs []int64, featureID int64) error {
+	key := r.keys.Banner(tenantID, tagIDs, featureID)
+
+	if err := r.client.Del(ctx, key).Err(); err != nil {
+		return errors.Wrapf(err, "error when try delete cache with key: %s", key)
 	}
 
-	result := strings.Join(strNumbers, ",")
+	return nil
+}
 
-	return result
+func (r *Redis) Stats() []domain.CacheStats {
+	return []domain.CacheStats{{
+		Tier:   "redis",
+		Hits:   r.hits.Load(),
+		Misses: r.misses.Load(),
+	}}
 }
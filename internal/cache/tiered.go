@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"errors"
+	"log"
+
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+// Tiered chains several caches, the first one being the fastest (L1).
+// A hit in a lower tier is copied into the tiers above it.
+type Tiered struct {
+	tiers []BannerCache
+}
+
+func NewTiered(tiers ...BannerCache) *Tiered {
+	return &Tiered{tiers: tiers}
+}
+
+func (t *Tiered) SaveBanner(entry Entry) error {
+	var errs []error
+	for _, tier := range t.tiers {
+		if err := tier.SaveBanner(entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *Tiered) GetBanner(tenantID string, tagIDs []int64, featureID int64) (*Entry, error) {
+	var errs []error
+	for i, tier := range t.tiers {
+		entry, err := tier.GetBanner(tenantID, tagIDs, featureID)
+		if err != nil {
+			if !errors.Is(err, ErrCacheMiss) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		// A failed back-fill only costs the upper tier a miss next time.
+		for _, upper := range t.tiers[:i] {
+			if err := upper.SaveBanner(*entry); err != nil {
+				log.Println("cache: back-fill failed: ", err)
+			}
+		}
+
+		return entry, nil
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return nil, ErrCacheMiss
+}
+
+// DeleteBanner evicts the entry from the lower tiers first, so that a
+// concurrent read can not copy it back into an already cleared upper tier.
+func (t *Tiered) DeleteBanner(tenantID string, tagIDs []int64, featureID int64) error {
+	var errs []error
+	for i := len(t.tiers) - 1; i >= 0; i-- {
+		if err := t.tiers[i].DeleteBanner(tenantID, tagIDs, featureID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *Tiered) Stats() []domain.CacheStats {
+	var stats []domain.CacheStats
+	for _, tier := range t.tiers {
+		stats = append(stats, tier.Stats()...)
+	}
+
+	return stats
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/config"
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+// brokenTier fails every call, like an unreachable Redis.
+type brokenTier struct{}
+
+var errBroken = errors.New("tier down")
+
+func (brokenTier) SaveBanner(entry Entry) error { return errBroken }
+func (brokenTier) GetBanner(tenantID string, tagIDs []int64, featureID int64) (*Entry, error) {
+	return nil, errBroken
+}
+func (brokenTier) DeleteBanner(tenantID string, tagIDs []int64, featureID int64) error {
+	return errBroken
+}
+func (brokenTier) Stats() []domain.CacheStats { return nil }
+
+func newTiers() (*LRU, *LRU, *Tiered) {
+	keys := NewKeyScheme(&config.Config{})
+	top, bottom := NewLRU(10, time.Hour, keys), NewLRU(10, time.Hour, keys)
+	return top, bottom, NewTiered(top, bottom)
+}
+
+func TestTieredFillsUpperTiers(t *testing.T) {
+	top, bottom, tiered := newTiers()
+
+	save(t, bottom, testEntry(t, 1, 1))
+
+	if !has(tiered, 1, 1) {
+		t.Fatal("entry of the bottom tier not found")
+	}
+	if !has(top, 1, 1) {
+		t.Error("top tier not filled from the bottom one")
+	}
+}
+
+func TestTieredSavesAndDeletesInAllTiers(t *testing.T) {
+	top, bottom, tiered := newTiers()
+
+	save(t, tiered, testEntry(t, 1, 1))
+	if !has(top, 1, 1) || !has(bottom, 1, 1) {
+		t.Fatal("entry not saved in every tier")
+	}
+
+	if err := tiered.DeleteBanner("shop", []int64{1}, 1); err != nil {
+		t.Fatalf("DeleteBanner() error = %v", err)
+	}
+	if has(top, 1, 1) || has(bottom, 1, 1) {
+		t.Error("entry kept in a tier after delete")
+	}
+	if _, err := tiered.GetBanner("shop", []int64{1}, 1); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("GetBanner() after delete error = %v, want %v", err, ErrCacheMiss)
+	}
+}
+
+func TestTieredSurvivesBrokenTier(t *testing.T) {
+	keys := NewKeyScheme(&config.Config{})
+	bottom := NewLRU(10, time.Hour, keys)
+	tiered := NewTiered(brokenTier{}, bottom)
+
+	save(t, bottom, testEntry(t, 1, 1))
+	if !has(tiered, 1, 1) {
+		t.Error("entry of a working tier not served past a broken one")
+	}
+
+	// Without a hit the failure is reported rather than hidden as a miss.
+	if _, err := tiered.GetBanner("shop", []int64{2}, 1); !errors.Is(err, errBroken) {
+		t.Errorf("GetBanner() error = %v, want %v", err, errBroken)
+	}
+}
@@ -1,9 +1,19 @@
 package config
 
 import (
+	"time"
+
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/panzerhomer/banner/internal/domain"
 )
 
+// Grant gives a role a permission, limited to the listed feature IDs or
+// ranges ("10-20") if any are given.
+type Grant struct {
+	Permission string   `yaml:"permission"`
+	Features   []string `yaml:"features"`
+}
+
 type Config struct {
 	Database struct {
 		Host     string `yaml:"host" env:"DB_HOST"`
@@ -11,15 +21,107 @@ type Config struct {
 		User     string `yaml:"user" env:"DB_USER"`
 		Password string `yaml:"password" env:"DB_PASSWORD"`
 		Name     string `yaml:"name" env:"DB_NAME"`
+		Pool     struct {
+			MaxConns          int32         `yaml:"max_conns" env:"DB_POOL_MAX_CONNS" env-default:"10"`
+			MinConns          int32         `yaml:"min_conns" env:"DB_POOL_MIN_CONNS"`
+			MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime" env:"DB_POOL_MAX_CONN_LIFETIME" env-default:"1h"`
+			MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time" env:"DB_POOL_MAX_CONN_IDLE_TIME" env-default:"30m"`
+			HealthCheckPeriod time.Duration `yaml:"health_check_period" env:"DB_POOL_HEALTH_CHECK_PERIOD" env-default:"1m"`
+		} `yaml:"pool"`
 	} `yaml:"database"`
 	Server struct {
 		Address string `yaml:"address" env:"SERVER_ADDRESS"`
 		Port    string `yaml:"port" env:"SERVER_PORT"`
 	} `yaml:"server"`
 	Redis struct {
-		Host string `yaml:"host" env:"DB_HOST"`
-		Port int    `yaml:"port" env:"DB_PORT"`
+		// Mode is "single", "sentinel" or "cluster". Sentinel and cluster use
+		// Addrs as the seed nodes; single falls back to Host:Port without them.
+		Mode       string   `yaml:"mode" env:"REDIS_MODE" env-default:"single"`
+		Host       string   `yaml:"host" env:"REDIS_HOST" env-default:"localhost"`
+		Port       int      `yaml:"port" env:"REDIS_PORT" env-default:"6379"`
+		Addrs      []string `yaml:"addrs" env:"REDIS_ADDRS" env-separator:","`
+		MasterName string   `yaml:"master_name" env:"REDIS_MASTER_NAME"`
+		Username   string   `yaml:"username" env:"REDIS_USERNAME"`
+		Password   string   `yaml:"password" env:"REDIS_PASSWORD"`
+		// SentinelPassword authenticates against the sentinels themselves.
+		SentinelPassword string `yaml:"sentinel_password" env:"REDIS_SENTINEL_PASSWORD"`
+		DB               int    `yaml:"db" env:"REDIS_DB"`
+		TLS              struct {
+			Enabled            bool   `yaml:"enabled" env:"REDIS_TLS_ENABLED"`
+			ServerName         string `yaml:"server_name" env:"REDIS_TLS_SERVER_NAME"`
+			InsecureSkipVerify bool   `yaml:"insecure_skip_verify" env:"REDIS_TLS_INSECURE_SKIP_VERIFY"`
+		} `yaml:"tls"`
+		PoolSize     int           `yaml:"pool_size" env:"REDIS_POOL_SIZE"`
+		MinIdleConns int           `yaml:"min_idle_conns" env:"REDIS_MIN_IDLE_CONNS"`
+		DialTimeout  time.Duration `yaml:"dial_timeout" env:"REDIS_DIAL_TIMEOUT" env-default:"5s"`
+		ReadTimeout  time.Duration `yaml:"read_timeout" env:"REDIS_READ_TIMEOUT" env-default:"3s"`
+		WriteTimeout time.Duration `yaml:"write_timeout" env:"REDIS_WRITE_TIMEOUT" env-default:"3s"`
+		PoolTimeout  time.Duration `yaml:"pool_timeout" env:"REDIS_POOL_TIMEOUT" env-default:"4s"`
 	} `yaml:"redis"`
+	Cache struct {
+		// Tiers lists the cache tiers from the fastest to the slowest,
+		// e.g. [memory, redis]. Known tiers are "memory" and "redis".
+		Tiers []string `yaml:"tiers" env:"CACHE_TIERS" env-separator:"," env-default:"redis"`
+		// TTL is how long an entry is fresh; after that it is served as a
+		// stale copy for StaleTTL while it is refreshed in the background,
+		// and as a fallback if the database fails.
+		TTL      time.Duration `yaml:"ttl" env:"CACHE_TTL" env-default:"5m"`
+		StaleTTL time.Duration `yaml:"stale_ttl" env:"CACHE_STALE_TTL" env-default:"1h"`
+		// NegativeTTL is how long a lookup that found no banner is remembered.
+		NegativeTTL time.Duration `yaml:"negative_ttl" env:"CACHE_NEGATIVE_TTL" env-default:"30s"`
+		// LookupTimeout bounds the database lookup that fills a cache miss.
+		// The lookup is shared by every request waiting for the same banner
+		// and by background refreshes, so it does not follow their contexts.
+		LookupTimeout time.Duration `yaml:"lookup_timeout" env:"CACHE_LOOKUP_TIMEOUT" env-default:"5s"`
+		// KeyPrefix namespaces the keys of this service; bumping KeyVersion
+		// invalidates every cached entry at once.
+		KeyPrefix  string `yaml:"key_prefix" env:"CACHE_KEY_PREFIX" env-default:"banner-service"`
+		KeyVersion int    `yaml:"key_version" env:"CACHE_KEY_VERSION" env-default:"1"`
+		Memory     struct {
+			Size int           `yaml:"size" env:"CACHE_MEMORY_SIZE" env-default:"10000"`
+			TTL  time.Duration `yaml:"ttl" env:"CACHE_MEMORY_TTL" env-default:"30s"`
+		} `yaml:"memory"`
+		// Bus broadcasts evictions to the in-process tiers and API key caches
+		// of other replicas over Redis pub/sub.
+		Bus struct {
+			Enabled        bool          `yaml:"enabled" env:"CACHE_BUS_ENABLED"`
+			ResyncInterval time.Duration `yaml:"resync_interval" env:"CACHE_BUS_RESYNC_INTERVAL" env-default:"30s"`
+		} `yaml:"bus"`
+		// WarmUp fills the cache with every live banner on startup, before
+		// the instance reports itself ready.
+		WarmUp struct {
+			Enabled     bool          `yaml:"enabled" env:"CACHE_WARMUP_ENABLED"`
+			Concurrency int           `yaml:"concurrency" env:"CACHE_WARMUP_CONCURRENCY" env-default:"8"`
+			Budget      time.Duration `yaml:"budget" env:"CACHE_WARMUP_BUDGET" env-default:"30s"`
+		} `yaml:"warmup"`
+	} `yaml:"cache"`
+	Versions struct {
+		Retention domain.RetentionPolicy           `yaml:"retention"`
+		Features  map[int64]domain.RetentionPolicy `yaml:"features"`
+		Banners   map[int64]domain.RetentionPolicy `yaml:"banners"`
+	} `yaml:"versions"`
+	Scheduler struct {
+		Enabled   bool          `yaml:"enabled" env:"SCHEDULER_ENABLED"`
+		Interval  time.Duration `yaml:"interval" env:"SCHEDULER_INTERVAL" env-default:"5s"`
+		BatchSize int           `yaml:"batch_size" env:"SCHEDULER_BATCH_SIZE" env-default:"100"`
+	} `yaml:"scheduler"`
+	Auth struct {
+		// Algorithm is HS256 (Secret or SecretFile) or RS256 (PublicKeyFile
+		// to verify and, to issue tokens, PrivateKeyFile). Keys are PEM
+		// files. The secret is never part of the config file.
+		Algorithm      string        `yaml:"algorithm" env:"AUTH_ALGORITHM" env-default:"HS256"`
+		Secret         string        `yaml:"-" env:"AUTH_SECRET"`
+		SecretFile     string        `yaml:"secret_file" env:"AUTH_SECRET_FILE"`
+		PublicKeyFile  string        `yaml:"public_key_file" env:"AUTH_PUBLIC_KEY_FILE"`
+		PrivateKeyFile string        `yaml:"private_key_file" env:"AUTH_PRIVATE_KEY_FILE"`
+		Issuer         string        `yaml:"issuer" env:"AUTH_ISSUER" env-default:"banner-service"`
+		TokenTTL       time.Duration `yaml:"token_ttl" env:"AUTH_TOKEN_TTL" env-default:"24h"`
+	} `yaml:"auth"`
+	RBAC struct {
+		// Roles adds roles or redefines the built-in viewer, editor,
+		// publisher, admin and user roles.
+		Roles map[string][]Grant `yaml:"roles"`
+	} `yaml:"rbac"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -0,0 +1,41 @@
+package domain
+
+import "time"
+
+// APIKey is a revocable credential of a client application. Only a hash of
+// the key itself is stored; Prefix is kept to tell keys apart.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Tenant     string     `json:"tenant"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Role       string     `json:"role"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// UsableAt reports whether the key authenticates requests at t.
+func (k *APIKey) UsableAt(t time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || t.Before(*k.ExpiresAt)
+}
+
+type APIKeyRequest struct {
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// IssuedAPIKey is returned once, when a key is created or rotated. The key
+// cannot be read back later.
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
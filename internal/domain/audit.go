@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audited actions.
+const (
+	AuditBannerCreate    = "banner.create"
+	AuditBannerUpdate    = "banner.update"
+	AuditBannerDelete    = "banner.delete"
+	AuditVersionActivate = "banner.version.activate"
+	AuditVersionSubmit   = "banner.version.submit"
+	AuditVersionApprove  = "banner.version.approve"
+	AuditVersionReject   = "banner.version.reject"
+	AuditVersionsPrune   = "banner.versions.prune"
+	AuditAPIKeyCreate    = "api_key.create"
+	AuditAPIKeyRotate    = "api_key.rotate"
+	AuditAPIKeyRevoke    = "api_key.revoke"
+	// AuditTransitionPrefix is followed by the action of a scheduled
+	// transition, e.g. banner.transition.expire.
+	AuditTransitionPrefix = "banner.transition."
+)
+
+// AuditEntry records one mutation: who did what to which banner, with the
+// state before and after it.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	BannerID  *int64          `json:"banner_id,omitempty"`
+	FeatureID *int64          `json:"feature_id,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AuditFilter selects audit entries. Entries are returned newest first;
+// Cursor continues after the last entry of the previous page.
+type AuditFilter struct {
+	Actor     string
+	BannerID  int64
+	FeatureID int64
+	From      *time.Time
+	To        *time.Time
+	Cursor    int64
+	Limit     int64
+	// Features limits the entries to those about the given features, as
+	// for callers that may only read the log of some features. Entries
+	// about no feature are left out then. Nil means no limit.
+	Features []FeatureRange
+}
+
+// FeatureRange is an inclusive range of feature IDs.
+type FeatureRange struct {
+	From, To int64
+}
+
+type AuditPage struct {
+	Entries    []AuditEntry `json:"entries"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
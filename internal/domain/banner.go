@@ -2,24 +2,76 @@ package domain
 
 import (
 	"errors"
+	"sort"
 	"time"
 )
 
 type Banner struct {
-	BannerID  int64     `json:"banner_id,omitempty"`
-	TagIds    []int64   `json:"tag_ids,omitempty"`
-	FeatureID int64     `json:"feature_id,omitempty"`
-	Content   any       `json:"content,omitempty"`
-	IsActive  bool      `json:"is_active,omitempty"`
+	BannerID int64 `json:"banner_id,omitempty"`
+	// Tenant is the product line the banner belongs to. It is filled in by
+	// the repository on reads; writes always go to the caller's tenant.
+	Tenant    string     `json:"tenant,omitempty"`
+	TagIds    []int64    `json:"tag_ids,omitempty"`
+	FeatureID int64      `json:"feature_id,omitempty"`
+	Content   any        `json:"content,omitempty"`
+	IsActive  bool       `json:"is_active,omitempty"`
+	StartAt   *time.Time `json:"start_at,omitempty"`
+	EndAt     *time.Time `json:"end_at,omitempty"`
+	// Revision grows with every change of the banner. Writes must name the
+	// revision they were based on.
+	Revision  int64     `json:"revision,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
 }
 
+// Revision workflow statuses. Only published revisions are served to users.
+const (
+	VersionDraft     = "draft"
+	VersionInReview  = "in_review"
+	VersionPublished = "published"
+)
+
+type BannerVersion struct {
+	VersionID int64      `json:"version"`
+	BannerID  int64      `json:"banner_id"`
+	TagIds    []int64    `json:"tag_ids"`
+	FeatureID int64      `json:"feature_id"`
+	Content   any        `json:"content,omitempty"`
+	IsActive  bool       `json:"is_active"`
+	StartAt   *time.Time `json:"start_at,omitempty"`
+	EndAt     *time.Time `json:"end_at,omitempty"`
+	Status    string     `json:"status"`
+	Active    bool       `json:"active"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type BannerDiff struct {
+	BannerID int64            `json:"banner_id"`
+	From     int64            `json:"from"`
+	To       int64            `json:"to"`
+	Patch    []PatchOperation `json:"patch"`
+	Summary  []string         `json:"summary"`
+}
+
+// BannerLookup is the result of a user banner lookup. Stale is set if the
+// database failed and the banners come from an expired cache entry.
+// ETag validates the banners and MaxAge is how long clients may reuse them
+// without asking again.
+type BannerLookup struct {
+	Banners []Banner
+	Stale   bool
+	ETag    string
+	MaxAge  time.Duration
+}
+
 type BannerRequest struct {
-	TagIds    []int64 `json:"tag_ids,omitempty"`
-	FeatureID int64   `json:"feature_id,omitempty"`
-	Content   any     `json:"content,omitempty"`
-	IsActive  bool    `json:"is_active,omitempty"`
+	TagIds    []int64    `json:"tag_ids,omitempty"`
+	FeatureID int64      `json:"feature_id,omitempty"`
+	Content   any        `json:"content,omitempty"`
+	IsActive  bool       `json:"is_active,omitempty"`
+	StartAt   *time.Time `json:"start_at,omitempty"`
+	EndAt     *time.Time `json:"end_at,omitempty"`
 }
 
 func (b *Banner) Validate() error {
@@ -31,9 +83,52 @@ func (b *Banner) Validate() error {
 		return errors.New("feature_id must be a positive integer")
 	}
 
+	if b.StartAt != nil && b.EndAt != nil && !b.StartAt.Before(*b.EndAt) {
+		return errors.New("start_at must be before end_at")
+	}
+
 	return nil
 }
 
+// NormalizeTags returns the tags sorted and without duplicates, the form they
+// are stored and looked up in, so that tag order never matters.
+func NormalizeTags(tagIDs []int64) []int64 {
+	if tagIDs == nil {
+		return nil
+	}
+
+	tags := make([]int64, len(tagIDs))
+	copy(tags, tagIDs)
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	unique := tags[:0]
+	for i, tag := range tags {
+		if i == 0 || tag != tags[i-1] {
+			unique = append(unique, tag)
+		}
+	}
+
+	return unique
+}
+
+// LiveAt reports whether the banner is shown to users at the given moment:
+// it has to be active and inside its activation window.
+func (b *Banner) LiveAt(t time.Time) bool {
+	if !b.IsActive {
+		return false
+	}
+
+	if b.StartAt != nil && t.Before(*b.StartAt) {
+		return false
+	}
+
+	if b.EndAt != nil && !t.Before(*b.EndAt) {
+		return false
+	}
+
+	return true
+}
+
 type BannerFilter struct {
 	TagIds        []int64
 	FeatureID     int64
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBannerLiveAt(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		inactive bool
+		startAt  *time.Time
+		endAt    *time.Time
+		want     bool
+	}{
+		{"no window", false, nil, nil, true},
+		{"switched off", true, nil, nil, false},
+		{"switched off inside the window", true, &before, &after, false},
+		{"before start", false, &after, nil, false},
+		{"at start", false, &now, nil, true},
+		{"after start", false, &before, nil, true},
+		{"inside the window", false, &before, &after, true},
+		{"before end", false, nil, &after, true},
+		{"at end", false, nil, &now, false},
+		{"after end", false, nil, &before, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Banner{IsActive: !tt.inactive, StartAt: tt.startAt, EndAt: tt.endAt}
+			if got := b.LiveAt(now); got != tt.want {
+				t.Errorf("LiveAt() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
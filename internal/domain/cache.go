@@ -0,0 +1,29 @@
+package domain
+
+// CacheStats are the hit and miss counters of one cache tier.
+type CacheStats struct {
+	Tier    string `json:"tier"`
+	Hits    int64  `json:"hits"`
+	Misses  int64  `json:"misses"`
+	Entries int64  `json:"entries,omitempty"`
+}
+
+// CacheReport describes the cache tiers and the database lookups made for
+// cache misses. Coalesced counts the callers that shared the result of a
+// lookup already in flight instead of querying the database themselves.
+type CacheReport struct {
+	Tiers     []CacheStats `json:"tiers"`
+	Lookups   int64        `json:"lookups"`
+	Queries   int64        `json:"queries"`
+	Coalesced int64        `json:"coalesced"`
+}
+
+// WarmUpReport describes a cache warm-up run. Complete is false if the time
+// budget ran out before every banner was cached.
+type WarmUpReport struct {
+	Banners  int    `json:"banners"`
+	Cached   int64  `json:"cached"`
+	Failed   int64  `json:"failed"`
+	Duration string `json:"duration"`
+	Complete bool   `json:"complete"`
+}
@@ -0,0 +1,15 @@
+package domain
+
+import "errors"
+
+var (
+	ErrBannerNotFound        = errors.New("banner not found")
+	ErrBannerVersionNotFound = errors.New("banner version not found")
+	ErrBannerVersionConflict = errors.New("banner version status does not allow this action")
+	ErrBannerRevisionStale   = errors.New("banner was changed since the given revision")
+	ErrAccessDenied          = errors.New("access denied")
+	ErrAPIKeyNotFound        = errors.New("api key not found")
+	ErrAPIKeyInvalid         = errors.New("api key is invalid, expired or revoked")
+	ErrAPIKeyIncorrectData   = errors.New("incorrect api key data")
+	ErrAuditIncorrectData    = errors.New("incorrect audit filter")
+)
@@ -0,0 +1,37 @@
+package domain
+
+import "encoding/json"
+
+// RFC 6902 operations used in revision diffs.
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+)
+
+// PatchOperation is one operation of a JSON Patch document. Old holds the
+// value the operation replaces or removes, for summaries; it is not part of
+// the document.
+type PatchOperation struct {
+	Op    string
+	Path  string
+	Value any
+	Old   any
+}
+
+// MarshalJSON always writes "value" for add and replace operations, even if
+// it is null, and never writes it for remove.
+func (o PatchOperation) MarshalJSON() ([]byte, error) {
+	if o.Op == PatchRemove {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{o.Op, o.Path})
+	}
+
+	return json.Marshal(struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		Value any    `json:"value"`
+	}{o.Op, o.Path, o.Value})
+}
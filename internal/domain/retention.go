@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// RetentionPolicy describes how many revisions of a banner are kept.
+// Keep limits the number of revisions, Days limits their age; zero disables
+// the corresponding limit, so the zero value keeps every revision forever.
+// The same type is read from the versions section of the config.
+type RetentionPolicy struct {
+	Keep int `json:"keep" yaml:"keep"`
+	Days int `json:"days" yaml:"days"`
+}
+
+func (p RetentionPolicy) Forever() bool {
+	return p.Keep <= 0 && p.Days <= 0
+}
+
+// Cutoff returns the moment before which revisions expire, or nil if the
+// policy has no age limit.
+func (p RetentionPolicy) Cutoff(now time.Time) *time.Time {
+	if p.Days <= 0 {
+		return nil
+	}
+
+	cutoff := now.AddDate(0, 0, -p.Days)
+	return &cutoff
+}
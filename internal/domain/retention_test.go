@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionPolicyForever(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   bool
+	}{
+		{"zero value", RetentionPolicy{}, true},
+		{"negative limits", RetentionPolicy{Keep: -1, Days: -1}, true},
+		{"keep limit", RetentionPolicy{Keep: 3}, false},
+		{"age limit", RetentionPolicy{Days: 30}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Forever(); got != tt.want {
+				t.Errorf("Forever() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetentionPolicyCutoff(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   *time.Time
+	}{
+		{"keep forever", RetentionPolicy{}, nil},
+		{"count limit only", RetentionPolicy{Keep: 5}, nil},
+		{"one day", RetentionPolicy{Days: 1}, ptr(time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC))},
+		{"thirty days", RetentionPolicy{Keep: 5, Days: 30}, ptr(time.Date(2024, 2, 9, 12, 0, 0, 0, time.UTC))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Cutoff(now)
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("Cutoff() = %s, want nil", got)
+			case tt.want != nil && got == nil:
+				t.Errorf("Cutoff() = nil, want %s", tt.want)
+			case tt.want != nil && !got.Equal(*tt.want):
+				t.Errorf("Cutoff() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// Scheduled lifecycle transitions of a banner.
+const (
+	TransitionPublish = "publish"
+	TransitionExpire  = "expire"
+)
+
+type BannerTransition struct {
+	ID         int64      `json:"id"`
+	BannerID   int64      `json:"banner_id"`
+	Tenant     string     `json:"tenant,omitempty"`
+	Action     string     `json:"action"`
+	RunAt      time.Time  `json:"run_at"`
+	ExecutedAt *time.Time `json:"executed_at,omitempty"`
+	ExecutedBy string     `json:"executed_by,omitempty"`
+	TagIds     []int64    `json:"tag_ids,omitempty"`
+	FeatureID  int64      `json:"feature_id,omitempty"`
+}
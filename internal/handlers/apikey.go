@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/utils"
+)
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, request domain.APIKeyRequest) (domain.IssuedAPIKey, error)
+	GetAPIKeys(ctx context.Context) ([]domain.APIKey, error)
+	RotateAPIKey(ctx context.Context, id int64) (domain.IssuedAPIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+}
+
+type apiKeyHandler struct {
+	servo APIKeyService
+}
+
+func NewAPIKeyHandler(servo APIKeyService) *apiKeyHandler {
+	return &apiKeyHandler{servo: servo}
+}
+
+func (h *apiKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var request domain.APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.ResponseJSON(w, utils.Error, domain.ErrAPIKeyIncorrectData.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.servo.CreateAPIKey(r.Context(), request)
+	if err != nil {
+		if errors.Is(err, domain.ErrAPIKeyIncorrectData) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respondServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	render.JSON(w, r, key)
+}
+
+func (h *apiKeyHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.servo.GetAPIKeys(r.Context())
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	if keys == nil {
+		keys = []domain.APIKey{}
+	}
+
+	render.JSON(w, r, keys)
+}
+
+func (h *apiKeyHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ResponseJSON(w, utils.Error, domain.ErrAPIKeyIncorrectData.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.servo.RotateAPIKey(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, domain.ErrAPIKeyNotFound) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusNotFound)
+			return
+		}
+		respondServiceError(w, err)
+		return
+	}
+
+	render.JSON(w, r, key)
+}
+
+func (h *apiKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ResponseJSON(w, utils.Error, domain.ErrAPIKeyIncorrectData.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.servo.RevokeAPIKey(r.Context(), id); err != nil {
+		if errors.Is(err, domain.ErrAPIKeyNotFound) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusNotFound)
+			return
+		}
+		respondServiceError(w, err)
+		return
+	}
+
+	utils.ResponseJSON(w, "", "", http.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/utils"
+)
+
+type AuditService interface {
+	GetAuditEntries(ctx context.Context, filter domain.AuditFilter) (domain.AuditPage, error)
+}
+
+type auditHandler struct {
+	servo AuditService
+}
+
+func NewAuditHandler(servo AuditService) *auditHandler {
+	return &auditHandler{servo: servo}
+}
+
+func (h *auditHandler) GetAuditEntries(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilter(r)
+	if err != nil {
+		utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.servo.GetAuditEntries(r.Context(), filter)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	render.JSON(w, r, page)
+}
+
+// auditFilter reads the filter from the query: actor, banner_id, feature_id,
+// from and to as RFC 3339 times, limit and cursor.
+func auditFilter(r *http.Request) (domain.AuditFilter, error) {
+	queryParams := r.URL.Query()
+
+	filter := domain.AuditFilter{Actor: queryParams.Get("actor")}
+
+	ints := map[string]*int64{
+		"banner_id":  &filter.BannerID,
+		"feature_id": &filter.FeatureID,
+		"limit":      &filter.Limit,
+		"cursor":     &filter.Cursor,
+	}
+	for name, dst := range ints {
+		value := queryParams.Get(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			return domain.AuditFilter{}, fmt.Errorf("%w: invalid %s", domain.ErrAuditIncorrectData, name)
+		}
+		*dst = n
+	}
+
+	times := map[string]**time.Time{
+		"from": &filter.From,
+		"to":   &filter.To,
+	}
+	for name, dst := range times {
+		value := queryParams.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return domain.AuditFilter{}, fmt.Errorf("%w: invalid %s", domain.ErrAuditIncorrectData, name)
+		}
+		*dst = &t
+	}
+
+	return filter, nil
+}
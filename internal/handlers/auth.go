@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/utils"
+)
+
+type TokenVerifier interface {
+	Verify(token string) (auth.Principal, error)
+}
+
+type KeyResolver interface {
+	ResolveAPIKey(ctx context.Context, key string) (auth.Principal, error)
+}
+
+// Auth authenticates the bearer token of a request, taken from the
+// Authorization header, the X-API-Key header or, for older clients, the
+// token header. API keys are resolved through keys, anything else must be a
+// JWT. A request without a token passes through without a principal; an
+// invalid one is rejected right away.
+func Auth(verifier TokenVerifier, keys KeyResolver) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			var (
+				principal auth.Principal
+				err       error
+			)
+			if auth.IsAPIKey(token) {
+				principal, err = keys.ResolveAPIKey(r.Context(), token)
+			} else {
+				principal, err = verifier.Verify(token)
+			}
+			if err != nil {
+				if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, domain.ErrAPIKeyInvalid) {
+					utils.ResponseJSON(w, utils.Error, ErrUserNotAuthorized.Error(), http.StatusUnauthorized)
+					return
+				}
+				utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+
+			handler.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+
+	return r.Header.Get("token")
+}
+
+type PermissionChecker interface {
+	Allows(principal auth.Principal, permission auth.Permission) bool
+}
+
+// RequirePermission lets a request through only if its principal's role has
+// the permission for at least some features. Checks against the feature of
+// the banner involved are done by the service.
+func RequirePermission(checker PermissionChecker, permission auth.Permission) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := authenticated(w, r)
+			if !ok {
+				return
+			}
+
+			if !checker.Allows(principal, permission) {
+				utils.ResponseJSON(w, utils.Error, ErrUserNotAllowed.Error(), http.StatusForbidden)
+				return
+			}
+
+			handler.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// authenticated returns the principal of the request, and answers 401 if
+// there is none.
+func authenticated(w http.ResponseWriter, r *http.Request) (auth.Principal, bool) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.ResponseJSON(w, utils.Error, ErrUserNotAuthorized.Error(), http.StatusUnauthorized)
+		return auth.Principal{}, false
+	}
+
+	return principal, true
+}
+
+type authHandler struct {
+	verifier TokenVerifier
+	keys     KeyResolver
+	checker  PermissionChecker
+}
+
+func NewAuthHandler(verifier TokenVerifier, keys KeyResolver, checker PermissionChecker) *authHandler {
+	return &authHandler{verifier: verifier, keys: keys, checker: checker}
+}
+
+type introspection struct {
+	Active  bool   `json:"active"`
+	Subject string `json:"sub,omitempty"`
+	Role    string `json:"role,omitempty"`
+	Tenant  string `json:"tenant,omitempty"`
+	Expiry  int64  `json:"exp,omitempty"`
+}
+
+// Introspect reports whether a token is valid and what it grants, in the
+// shape of RFC 7662. Only authenticated callers may introspect tokens, and
+// tokens of other tenants are reported as inactive.
+func (h *authHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	caller, ok := authenticated(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		utils.ResponseJSON(w, utils.Error, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	var result introspection
+	if principal, err := h.verifier.Verify(request.Token); err == nil && principal.Tenant == caller.Tenant {
+		result = introspection{
+			Active:  true,
+			Subject: principal.Subject,
+			Role:    principal.Role,
+			Tenant:  principal.Tenant,
+			Expiry:  principal.ExpiresAt.Unix(),
+		}
+	}
+
+	render.JSON(w, r, result)
+}
@@ -3,28 +3,37 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
 	"github.com/panzerhomer/banner/internal/domain"
 	"github.com/panzerhomer/banner/internal/utils"
 )
 
-var ctx = context.Background()
-
-const (
-	AdminToken = "admin_token"
-	UserToken  = "user_token"
-)
+// StaleHeader marks user banners served from a stale cache copy, either
+// while it is refreshed or because the database could not be reached.
+const StaleHeader = "X-Banner-Stale"
 
 type BannerService interface {
 	CreateBanner(ctx context.Context, banner domain.Banner) (int64, error)
 	GetBanners(ctx context.Context, banner domain.BannerFilter) ([]domain.Banner, error)
-	GetBanner(ctx context.Context, tagIDs []int64, featureID int64, lastVersion bool, isAdmin bool) ([]domain.Banner, error)
+	GetBanner(ctx context.Context, tagIDs []int64, featureID int64, lastVersion bool) (domain.BannerLookup, error)
 	UpdateBanner(ctx context.Context, banner domain.Banner) error
-	DeleteBanner(ctx context.Context, bannerID int64) error
+	DeleteBanner(ctx context.Context, bannerID int64, revision int64) error
+	GetBannerVersions(ctx context.Context, bannerID int64) ([]domain.BannerVersion, error)
+	ActivateBannerVersion(ctx context.Context, bannerID int64, versionID int64) (int64, error)
+	PruneBannerVersions(ctx context.Context, bannerID int64) (int64, error)
+	DiffBannerVersions(ctx context.Context, bannerID int64, fromID int64, toID int64) (domain.BannerDiff, error)
+	SubmitBannerVersion(ctx context.Context, bannerID int64, versionID int64) error
+	ApproveBannerVersion(ctx context.Context, bannerID int64, versionID int64) error
+	RejectBannerVersion(ctx context.Context, bannerID int64, versionID int64) error
+	CacheStats(ctx context.Context) domain.CacheReport
+	WarmUp(ctx context.Context) (domain.WarmUpReport, error)
 }
 
 type bannerHandler struct {
@@ -36,17 +45,6 @@ func NewBannerHandler(servo BannerService) *bannerHandler {
 }
 
 func (h *bannerHandler) CreateBanner(w http.ResponseWriter, r *http.Request) {
-	token, ok := r.Context().Value("token").(string)
-	if token == "" && !ok {
-		utils.ResponseJSON(w, utils.Error, ErrUserNotAuthorized.Error(), http.StatusUnauthorized)
-		return
-	}
-
-	if token != AdminToken {
-		utils.ResponseJSON(w, utils.Error, ErrUserNotAllowed.Error(), http.StatusForbidden)
-		return
-	}
-
 	var banner domain.Banner
 	if err := json.NewDecoder(r.Body).Decode(&banner); err != nil {
 		utils.ResponseJSON(w, utils.Error, ErrBannerIncorrectData.Error(), http.StatusInternalServerError)
@@ -58,9 +56,9 @@ func (h *bannerHandler) CreateBanner(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bannerID, err := h.servo.CreateBanner(ctx, banner)
+	bannerID, err := h.servo.CreateBanner(r.Context(), banner)
 	if err != nil {
-		utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusInternalServerError)
+		respondServiceError(w, err)
 		return
 	}
 
@@ -68,17 +66,6 @@ func (h *bannerHandler) CreateBanner(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *bannerHandler) GetBannersWithFilter(w http.ResponseWriter, r *http.Request) {
-	token, ok := r.Context().Value("token").(string)
-	if token == "" && !ok {
-		utils.ResponseJSON(w, utils.Error, ErrUserNotAuthorized.Error(), http.StatusUnauthorized)
-		return
-	}
-
-	if token != AdminToken {
-		utils.ResponseJSON(w, utils.Error, ErrUserNotAllowed.Error(), http.StatusForbidden)
-		return
-	}
-
 	queryParams := r.URL.Query()
 	featureIdParam := queryParams.Get("feature_id")
 	tagsParam := queryParams.Get("tag_ids")
@@ -111,9 +98,9 @@ func (h *bannerHandler) GetBannersWithFilter(w http.ResponseWriter, r *http.Requ
 		Offset:    int64(offset),
 	}
 
-	banners, err := h.servo.GetBanners(ctx, bannerWithFilter)
+	banners, err := h.servo.GetBanners(r.Context(), bannerWithFilter)
 	if err != nil {
-		utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusInternalServerError)
+		respondServiceError(w, err)
 		return
 	}
 
@@ -125,22 +112,6 @@ func (h *bannerHandler) GetBannersWithFilter(w http.ResponseWriter, r *http.Requ
 }
 
 func (h *bannerHandler) GetUserBanner(w http.ResponseWriter, r *http.Request) {
-	token, ok := r.Context().Value("token").(string)
-	if token == "" && !ok {
-		utils.ResponseJSON(w, utils.Error, ErrUserNotAuthorized.Error(), http.StatusUnauthorized)
-		return
-	}
-
-	var isAdmin bool
-	if token == AdminToken {
-		isAdmin = true
-	}
-
-	if token != UserToken {
-		utils.ResponseJSON(w, utils.Error, ErrUserNotAllowed.Error(), http.StatusForbidden)
-		return
-	}
-
 	queryParams := r.URL.Query()
 	featureIdParam := queryParams.Get("feature_id")
 	tagsParam := queryParams.Get("tag_ids")
@@ -164,35 +135,76 @@ func (h *bannerHandler) GetUserBanner(w http.ResponseWriter, r *http.Request) {
 	featureId, _ := strconv.Atoi(featureIdParam)
 	lastVersion := strToBool(lastVersionParam)
 
-	banners, err := h.servo.GetBanner(ctx, tags, int64(featureId), lastVersion, isAdmin)
+	lookup, err := h.servo.GetBanner(r.Context(), tags, int64(featureId), lastVersion)
 	if err != nil {
-		utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, domain.ErrBannerNotFound) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusNotFound)
+			return
+		}
+		respondServiceError(w, err)
+		return
+	}
+
+	if lookup.Stale {
+		w.Header().Set(StaleHeader, "true")
+	}
+	w.Header().Set("ETag", lookup.ETag)
+	w.Header().Set("Cache-Control", cacheControl(lookup.MaxAge))
+
+	if etagMatches(r.Header.Get("If-None-Match"), lookup.ETag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if banners != nil {
-		render.JSON(w, r, banners)
+	if lookup.Banners != nil {
+		render.JSON(w, r, lookup.Banners)
 	}
 }
 
-func strToBool(s string) bool {
-	return strings.ToLower(s) == "true"
+// respondServiceError answers the errors any banner operation may fail with
+// that the handler does not map itself.
+func respondServiceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, domain.ErrAccessDenied) {
+		utils.ResponseJSON(w, utils.Error, ErrUserNotAllowed.Error(), http.StatusForbidden)
+		return
+	}
+
+	utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusInternalServerError)
 }
 
-func (h *bannerHandler) UpdateBanner(w http.ResponseWriter, r *http.Request) {
-	token, ok := r.Context().Value("token").(string)
-	if token == "" && !ok {
-		utils.ResponseJSON(w, utils.Error, ErrUserNotAuthorized.Error(), http.StatusUnauthorized)
-		return
+// cacheControl lets clients reuse a user banner for maxAge. Banners depend
+// on the caller's tags, so shared caches must not store them.
+func cacheControl(maxAge time.Duration) string {
+	if seconds := int64(maxAge / time.Second); seconds > 0 {
+		return "private, max-age=" + strconv.FormatInt(seconds, 10)
 	}
+	return "private, no-cache"
+}
 
-	if token != AdminToken {
-		utils.ResponseJSON(w, utils.Error, ErrUserNotAllowed.Error(), http.StatusForbidden)
-		return
+// etagMatches reports whether an If-None-Match header names etag. Weak
+// validators match too, as If-None-Match uses the weak comparison.
+func etagMatches(header string, etag string) bool {
+	if header == "" || etag == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
 	}
 
+	return false
+}
+
+func strToBool(s string) bool {
+	return strings.ToLower(s) == "true"
+}
+
+func (h *bannerHandler) UpdateBanner(w http.ResponseWriter, r *http.Request) {
 	var banner domain.Banner
 	if err := json.NewDecoder(r.Body).Decode(&banner); err != nil {
 		utils.ResponseJSON(w, utils.Error, ErrBannerIncorrectData.Error(), http.StatusInternalServerError)
@@ -210,8 +222,23 @@ func (h *bannerHandler) UpdateBanner(w http.ResponseWriter, r *http.Request) {
 	bannerId, _ := strconv.Atoi(bannerParamId)
 	banner.BannerID = int64(bannerId)
 
-	if err := h.servo.UpdateBanner(ctx, banner); err != nil {
-		utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusInternalServerError)
+	revision, ok := ifMatchRevision(r)
+	if !ok {
+		utils.ResponseJSON(w, utils.Error, ErrRevisionRequired.Error(), http.StatusPreconditionRequired)
+		return
+	}
+	banner.Revision = revision
+
+	if err := h.servo.UpdateBanner(r.Context(), banner); err != nil {
+		if errors.Is(err, domain.ErrBannerNotFound) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, domain.ErrBannerRevisionStale) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		respondServiceError(w, err)
 		return
 	}
 
@@ -219,25 +246,219 @@ func (h *bannerHandler) UpdateBanner(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *bannerHandler) DeleteBanner(w http.ResponseWriter, r *http.Request) {
-	token, ok := r.Context().Value("token").(string)
-	if token == "" && !ok {
-		utils.ResponseJSON(w, utils.Error, ErrUserNotAuthorized.Error(), http.StatusUnauthorized)
+	queryParams := r.URL.Query()
+	bannerParamId := queryParams.Get("id")
+	bannerId, _ := strconv.Atoi(bannerParamId)
+
+	revision, ok := ifMatchRevision(r)
+	if !ok {
+		utils.ResponseJSON(w, utils.Error, ErrRevisionRequired.Error(), http.StatusPreconditionRequired)
 		return
 	}
 
-	if token != AdminToken {
-		utils.ResponseJSON(w, utils.Error, ErrUserNotAllowed.Error(), http.StatusForbidden)
+	if err := h.servo.DeleteBanner(r.Context(), int64(bannerId), revision); err != nil {
+		if errors.Is(err, domain.ErrBannerNotFound) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, domain.ErrBannerRevisionStale) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		respondServiceError(w, err)
+		return
+	}
+
+	utils.ResponseJSON(w, "", "", http.StatusNoContent)
+}
+
+// ifMatchRevision reads the banner revision a write is based on from the
+// If-Match header. Both the quoted ETag form ("3") and a bare number are
+// accepted.
+func ifMatchRevision(r *http.Request) (int64, bool) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	value = strings.Trim(value, `"`)
+
+	revision, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || revision <= 0 {
+		return 0, false
+	}
+
+	return revision, true
+}
+
+func (h *bannerHandler) GetBannerVersions(w http.ResponseWriter, r *http.Request) {
+	bannerId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ResponseJSON(w, utils.Error, ErrBannerIncorrectData.Error(), http.StatusBadRequest)
+		return
+	}
+
+	versions, err := h.servo.GetBannerVersions(r.Context(), bannerId)
+	if err != nil {
+		if errors.Is(err, domain.ErrBannerNotFound) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusNotFound)
+			return
+		}
+		respondServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if versions != nil {
+		render.JSON(w, r, versions)
+	}
+}
+
+func (h *bannerHandler) ActivateBannerVersion(w http.ResponseWriter, r *http.Request) {
+	bannerId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ResponseJSON(w, utils.Error, ErrBannerIncorrectData.Error(), http.StatusBadRequest)
+		return
+	}
+
+	versionId, err := strconv.ParseInt(chi.URLParam(r, "version"), 10, 64)
+	if err != nil {
+		utils.ResponseJSON(w, utils.Error, ErrBannerIncorrectData.Error(), http.StatusBadRequest)
+		return
+	}
+
+	newVersionId, err := h.servo.ActivateBannerVersion(r.Context(), bannerId, versionId)
+	if err != nil {
+		if errors.Is(err, domain.ErrBannerNotFound) || errors.Is(err, domain.ErrBannerVersionNotFound) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, domain.ErrBannerVersionConflict) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusConflict)
+			return
+		}
+		respondServiceError(w, err)
+		return
+	}
+
+	utils.ResponseJSON(w, "version", newVersionId, http.StatusCreated)
+}
+
+func (h *bannerHandler) PruneBannerVersions(w http.ResponseWriter, r *http.Request) {
+	bannerId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ResponseJSON(w, utils.Error, ErrBannerIncorrectData.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pruned, err := h.servo.PruneBannerVersions(r.Context(), bannerId)
+	if err != nil {
+		if errors.Is(err, domain.ErrBannerNotFound) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusNotFound)
+			return
+		}
+		respondServiceError(w, err)
+		return
+	}
+
+	utils.ResponseJSON(w, "pruned", pruned, http.StatusOK)
+}
+
+func (h *bannerHandler) DiffBannerVersions(w http.ResponseWriter, r *http.Request) {
+	bannerId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ResponseJSON(w, utils.Error, ErrBannerIncorrectData.Error(), http.StatusBadRequest)
 		return
 	}
 
 	queryParams := r.URL.Query()
-	bannerParamId := queryParams.Get("id")
-	bannerId, _ := strconv.Atoi(bannerParamId)
 
-	if err := h.servo.DeleteBanner(ctx, int64(bannerId)); err != nil {
-		utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusInternalServerError)
+	fromId, err := strconv.ParseInt(queryParams.Get("from"), 10, 64)
+	if err != nil {
+		utils.ResponseJSON(w, utils.Error, ErrBannerIncorrectData.Error(), http.StatusBadRequest)
 		return
 	}
 
-	utils.ResponseJSON(w, "", "", http.StatusNoContent)
+	var toId int64
+	if toParam := queryParams.Get("to"); toParam != "" {
+		toId, err = strconv.ParseInt(toParam, 10, 64)
+		if err != nil {
+			utils.ResponseJSON(w, utils.Error, ErrBannerIncorrectData.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	diff, err := h.servo.DiffBannerVersions(r.Context(), bannerId, fromId, toId)
+	if err != nil {
+		if errors.Is(err, domain.ErrBannerNotFound) || errors.Is(err, domain.ErrBannerVersionNotFound) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusNotFound)
+			return
+		}
+		respondServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, diff)
+}
+
+func (h *bannerHandler) SubmitBannerVersion(w http.ResponseWriter, r *http.Request) {
+	h.transitionBannerVersion(w, r, h.servo.SubmitBannerVersion)
+}
+
+func (h *bannerHandler) ApproveBannerVersion(w http.ResponseWriter, r *http.Request) {
+	h.transitionBannerVersion(w, r, h.servo.ApproveBannerVersion)
+}
+
+func (h *bannerHandler) RejectBannerVersion(w http.ResponseWriter, r *http.Request) {
+	h.transitionBannerVersion(w, r, h.servo.RejectBannerVersion)
+}
+
+func (h *bannerHandler) transitionBannerVersion(w http.ResponseWriter, r *http.Request, transition func(ctx context.Context, bannerID int64, versionID int64) error) {
+	bannerId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ResponseJSON(w, utils.Error, ErrBannerIncorrectData.Error(), http.StatusBadRequest)
+		return
+	}
+
+	versionId, err := strconv.ParseInt(chi.URLParam(r, "version"), 10, 64)
+	if err != nil {
+		utils.ResponseJSON(w, utils.Error, ErrBannerIncorrectData.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := transition(r.Context(), bannerId, versionId); err != nil {
+		if errors.Is(err, domain.ErrBannerNotFound) || errors.Is(err, domain.ErrBannerVersionNotFound) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, domain.ErrBannerVersionConflict) {
+			utils.ResponseJSON(w, utils.Error, err.Error(), http.StatusConflict)
+			return
+		}
+		respondServiceError(w, err)
+		return
+	}
+
+	utils.ResponseJSON(w, "message", "ok", http.StatusOK)
+}
+
+func (h *bannerHandler) GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, h.servo.CacheStats(r.Context()))
+}
+
+func (h *bannerHandler) WarmUpCache(w http.ResponseWriter, r *http.Request) {
+	// The warm-up is bounded by its own time budget, which may exceed the
+	// server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	report, err := h.servo.WarmUp(r.Context())
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, report)
 }
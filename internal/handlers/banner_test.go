@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+// fakeBannerService answers with lookup and err, and records the revision
+// writes were based on. Other methods are not used by these tests.
+type fakeBannerService struct {
+	BannerService
+
+	lookup   domain.BannerLookup
+	err      error
+	revision int64
+	writes   int
+}
+
+func (s *fakeBannerService) GetBanner(ctx context.Context, tagIDs []int64, featureID int64, lastVersion bool) (domain.BannerLookup, error) {
+	return s.lookup, s.err
+}
+
+func (s *fakeBannerService) UpdateBanner(ctx context.Context, banner domain.Banner) error {
+	s.writes++
+	s.revision = banner.Revision
+	return s.err
+}
+
+func (s *fakeBannerService) DeleteBanner(ctx context.Context, bannerID int64, revision int64) error {
+	s.writes++
+	s.revision = revision
+	return s.err
+}
+
+func serve(handler http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestGetUserBannerStaleHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		stale bool
+		want  string
+	}{
+		{"fresh", false, ""},
+		{"stale", true, "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servo := &fakeBannerService{lookup: domain.BannerLookup{
+				Banners: []domain.Banner{{BannerID: 1, FeatureID: 1, TagIds: []int64{1}}},
+				Stale:   tt.stale,
+				ETag:    `"abc"`,
+			}}
+			h := NewBannerHandler(servo)
+
+			w := serve(h.GetUserBanner, httptest.NewRequest(http.MethodGet, "/user-banner?feature_id=1&tag_ids=1", nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if got := w.Header().Get(StaleHeader); got != tt.want {
+				t.Errorf("%s = %q, want %q", StaleHeader, got, tt.want)
+			}
+			// A stale copy must be revalidated before it is reused.
+			if tt.stale && w.Header().Get("Cache-Control") != "private, no-cache" {
+				t.Errorf("Cache-Control = %q, want %q", w.Header().Get("Cache-Control"), "private, no-cache")
+			}
+		})
+	}
+}
+
+func TestGetUserBannerNotFound(t *testing.T) {
+	// Misses may be answered from the negative cache, clients see the same
+	// response as for a miss in the database.
+	h := NewBannerHandler(&fakeBannerService{err: domain.ErrBannerNotFound})
+
+	w := serve(h.GetUserBanner, httptest.NewRequest(http.MethodGet, "/user-banner?feature_id=1&tag_ids=1", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if want := `{"error":"banner not found"}`; w.Body.String() != want {
+		t.Errorf("body = %s, want %s", w.Body.String(), want)
+	}
+	if w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "" {
+		t.Errorf("caching headers on a 404: %v", w.Header())
+	}
+}
+
+func TestWritesRequireIfMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		ifMatch    string
+		err        error
+		wantStatus int
+		revision   int64
+	}{
+		{"missing", "", nil, http.StatusPreconditionRequired, 0},
+		{"not a revision", `"abc"`, nil, http.StatusPreconditionRequired, 0},
+		{"stale revision", `"3"`, domain.ErrBannerRevisionStale, http.StatusPreconditionFailed, 3},
+		{"current revision", `"4"`, nil, 0, 4},
+		{"bare number", "4", nil, 0, 4},
+	}
+
+	writes := []struct {
+		name       string
+		okStatus   int
+		handler    func(h *bannerHandler) http.HandlerFunc
+		newRequest func() *http.Request
+	}{
+		{"update", http.StatusOK, func(h *bannerHandler) http.HandlerFunc { return h.UpdateBanner }, func() *http.Request {
+			return httptest.NewRequest(http.MethodPatch, "/banner?id=1", strings.NewReader(`{"tag_ids":[1],"feature_id":1,"content":{"title":"a"}}`))
+		}},
+		{"delete", http.StatusNoContent, func(h *bannerHandler) http.HandlerFunc { return h.DeleteBanner }, func() *http.Request {
+			return httptest.NewRequest(http.MethodDelete, "/banner?id=1", nil)
+		}},
+	}
+
+	for _, write := range writes {
+		for _, tt := range tests {
+			t.Run(write.name+" "+tt.name, func(t *testing.T) {
+				servo := &fakeBannerService{err: tt.err}
+				h := NewBannerHandler(servo)
+
+				r := write.newRequest()
+				if tt.ifMatch != "" {
+					r.Header.Set("If-Match", tt.ifMatch)
+				}
+				w := serve(write.handler(h), r)
+
+				want := tt.wantStatus
+				if want == 0 {
+					want = write.okStatus
+				}
+				if w.Code != want {
+					t.Errorf("status = %d, want %d", w.Code, want)
+				}
+
+				// Without a revision nothing is written.
+				if tt.revision == 0 && servo.writes != 0 {
+					t.Errorf("service called %d times without a revision", servo.writes)
+				}
+				if servo.revision != tt.revision {
+					t.Errorf("revision = %d, want %d", servo.revision, tt.revision)
+				}
+			})
+		}
+	}
+}
+
+func TestGetUserBannerConditional(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"no validator", "", http.StatusOK},
+		{"strong match", `"abc"`, http.StatusNotModified},
+		{"weak match", `W/"abc"`, http.StatusNotModified},
+		{"match in a list", `"old", "abc"`, http.StatusNotModified},
+		{"any", "*", http.StatusNotModified},
+		{"other etag", `"old"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewBannerHandler(&fakeBannerService{lookup: domain.BannerLookup{
+				Banners: []domain.Banner{{BannerID: 1, FeatureID: 1, TagIds: []int64{1}}},
+				ETag:    `"abc"`,
+				MaxAge:  time.Minute,
+			}})
+
+			r := httptest.NewRequest(http.MethodGet, "/user-banner?feature_id=1&tag_ids=1", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := serve(h.GetUserBanner, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("ETag"); got != `"abc"` {
+				t.Errorf("ETag = %q, want %q", got, `"abc"`)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "private, max-age=60" {
+				t.Errorf("Cache-Control = %q, want %q", got, "private, max-age=60")
+			}
+			if tt.wantStatus == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 with a body: %s", w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && w.Body.Len() == 0 {
+				t.Error("200 without a body")
+			}
+		})
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	tests := []struct {
+		maxAge time.Duration
+		want   string
+	}{
+		{5 * time.Minute, "private, max-age=300"},
+		// A banner that ends in 1.5s may be reused for one more second only.
+		{1500 * time.Millisecond, "private, max-age=1"},
+		{500 * time.Millisecond, "private, no-cache"},
+		{0, "private, no-cache"},
+	}
+	for _, tt := range tests {
+		if got := cacheControl(tt.maxAge); got != tt.want {
+			t.Errorf("cacheControl(%s) = %q, want %q", tt.maxAge, got, tt.want)
+		}
+	}
+}
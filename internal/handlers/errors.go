@@ -7,4 +7,5 @@ var (
 	ErrUserNotAuthorized   = errors.New("user not authorized")
 	ErrUserNotAllowed      = errors.New("user not allowed")
 	ErrBannerExists        = errors.New("banner already exists")
+	ErrRevisionRequired    = errors.New("If-Match header with the banner revision is required")
 )
@@ -1,40 +1,72 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/utils"
 )
 
-func Auth(handler http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get("token")
+// Readiness answers readiness probes. It reports 503 until SetReady is called,
+// e.g. while the cache is warming up.
+type Readiness struct {
+	ready atomic.Bool
+}
 
-		ctx := context.WithValue(r.Context(), "token", token)
-		r = r.WithContext(ctx)
+func (rd *Readiness) SetReady() {
+	rd.ready.Store(true)
+}
 
-		handler.ServeHTTP(w, r)
+func (rd *Readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !rd.ready.Load() {
+		utils.ResponseJSON(w, "status", "starting", http.StatusServiceUnavailable)
+		return
 	}
 
-	return http.HandlerFunc(fn)
+	utils.ResponseJSON(w, "status", "ready", http.StatusOK)
 }
 
-func Routes(bannerHandler *bannerHandler) chi.Router {
+func Routes(bannerHandler *bannerHandler, authHandler *authHandler, apiKeyHandler *apiKeyHandler, auditHandler *auditHandler, readiness *Readiness) chi.Router {
 	root := chi.NewRouter()
 	root.Use(middleware.Logger)
 	root.Use(middleware.RequestID)
 
+	root.Get("/ready", readiness.ServeHTTP)
+
 	r := chi.NewRouter()
-	r.Use(Auth)
+	r.Use(Auth(authHandler.verifier, authHandler.keys))
 
 	root.Mount("/api", r)
-	r.Post("/banner", bannerHandler.CreateBanner)
-	r.Get("/banner", bannerHandler.GetBannersWithFilter)
-	r.Get("/user-banner", bannerHandler.GetUserBanner)
-	r.Patch("/banner", bannerHandler.UpdateBanner)
-	r.Delete("/banner", bannerHandler.DeleteBanner)
+
+	read := RequirePermission(authHandler.checker, auth.PermReadBanners)
+	write := RequirePermission(authHandler.checker, auth.PermWriteBanners)
+	publish := RequirePermission(authHandler.checker, auth.PermPublishBanners)
+	manageCache := RequirePermission(authHandler.checker, auth.PermManageCache)
+	manageKeys := RequirePermission(authHandler.checker, auth.PermManageKeys)
+
+	r.With(write).Post("/banner", bannerHandler.CreateBanner)
+	r.With(read).Get("/banner", bannerHandler.GetBannersWithFilter)
+	r.With(RequirePermission(authHandler.checker, auth.PermReadUserBanner)).Get("/user-banner", bannerHandler.GetUserBanner)
+	r.With(write).Patch("/banner", bannerHandler.UpdateBanner)
+	r.With(write).Delete("/banner", bannerHandler.DeleteBanner)
+	r.With(read).Get("/banner/{id}/versions", bannerHandler.GetBannerVersions)
+	r.With(publish).Post("/banner/{id}/versions/{version}/activate", bannerHandler.ActivateBannerVersion)
+	r.With(write).Post("/banner/{id}/versions/{version}/submit", bannerHandler.SubmitBannerVersion)
+	r.With(publish).Post("/banner/{id}/versions/{version}/approve", bannerHandler.ApproveBannerVersion)
+	r.With(publish).Post("/banner/{id}/versions/{version}/reject", bannerHandler.RejectBannerVersion)
+	r.With(write).Post("/banner/{id}/versions/prune", bannerHandler.PruneBannerVersions)
+	r.With(read).Get("/banner/{id}/versions/diff", bannerHandler.DiffBannerVersions)
+	r.With(manageCache).Get("/cache/stats", bannerHandler.GetCacheStats)
+	r.With(manageCache).Post("/cache/warmup", bannerHandler.WarmUpCache)
+	r.Post("/auth/introspect", authHandler.Introspect)
+	r.With(manageKeys).Post("/keys", apiKeyHandler.CreateAPIKey)
+	r.With(manageKeys).Get("/keys", apiKeyHandler.GetAPIKeys)
+	r.With(manageKeys).Post("/keys/{id}/rotate", apiKeyHandler.RotateAPIKey)
+	r.With(manageKeys).Delete("/keys/{id}", apiKeyHandler.RevokeAPIKey)
+	r.With(RequirePermission(authHandler.checker, auth.PermReadAudit)).Get("/audit", auditHandler.GetAuditEntries)
 
 	return root
 }
@@ -0,0 +1,142 @@
+// Package jsonpatch builds RFC 6902 JSON Patch documents describing the
+// difference between two JSON values.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+// Diff returns the operations that turn from into to. Both values are
+// normalized through encoding/json first, so any marshalable value works.
+func Diff(from, to any) ([]domain.PatchOperation, error) {
+	a, err := normalize(from)
+	if err != nil {
+		return nil, fmt.Errorf("jsonpatch: %w", err)
+	}
+
+	b, err := normalize(to)
+	if err != nil {
+		return nil, fmt.Errorf("jsonpatch: %w", err)
+	}
+
+	ops := []domain.PatchOperation{}
+	return diff(ops, "", a, b), nil
+}
+
+// Summary renders the operations as human-readable lines.
+func Summary(ops []domain.PatchOperation) []string {
+	lines := make([]string, 0, len(ops))
+	for _, op := range ops {
+		switch op.Op {
+		case domain.PatchAdd:
+			lines = append(lines, fmt.Sprintf("added %s = %s", op.Path, format(op.Value)))
+		case domain.PatchRemove:
+			lines = append(lines, fmt.Sprintf("removed %s (was %s)", op.Path, format(op.Old)))
+		case domain.PatchReplace:
+			lines = append(lines, fmt.Sprintf("changed %s from %s to %s", op.Path, format(op.Old), format(op.Value)))
+		}
+	}
+
+	return lines
+}
+
+func diff(ops []domain.PatchOperation, path string, a, b any) []domain.PatchOperation {
+	if reflect.DeepEqual(a, b) {
+		return ops
+	}
+
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+
+		for _, key := range sortedKeys(av) {
+			if _, ok := bv[key]; !ok {
+				ops = append(ops, domain.PatchOperation{Op: domain.PatchRemove, Path: path + "/" + escape(key), Old: av[key]})
+			}
+		}
+		for _, key := range sortedKeys(bv) {
+			old, ok := av[key]
+			if !ok {
+				ops = append(ops, domain.PatchOperation{Op: domain.PatchAdd, Path: path + "/" + escape(key), Value: bv[key]})
+				continue
+			}
+			ops = diff(ops, path+"/"+escape(key), old, bv[key])
+		}
+
+		return ops
+	case []any:
+		bv, ok := b.([]any)
+		if !ok {
+			break
+		}
+
+		common := len(av)
+		if len(bv) < common {
+			common = len(bv)
+		}
+
+		for i := 0; i < common; i++ {
+			ops = diff(ops, path+"/"+strconv.Itoa(i), av[i], bv[i])
+		}
+		// Removals go from the tail so that earlier indexes stay valid.
+		for i := len(av) - 1; i >= common; i-- {
+			ops = append(ops, domain.PatchOperation{Op: domain.PatchRemove, Path: path + "/" + strconv.Itoa(i), Old: av[i]})
+		}
+		for i := common; i < len(bv); i++ {
+			ops = append(ops, domain.PatchOperation{Op: domain.PatchAdd, Path: path + "/" + strconv.Itoa(i), Value: bv[i]})
+		}
+
+		return ops
+	}
+
+	return append(ops, domain.PatchOperation{Op: domain.PatchReplace, Path: path, Value: b, Old: a})
+}
+
+func normalize(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// escape encodes a key as an RFC 6901 JSON Pointer reference token.
+func escape(key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	return strings.ReplaceAll(key, "/", "~1")
+}
+
+func format(v any) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(raw)
+}
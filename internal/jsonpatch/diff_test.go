@@ -0,0 +1,131 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    any
+		to      any
+		patch   string
+		summary []string
+	}{
+		{
+			name:    "equal values",
+			from:    map[string]any{"title": "a", "tags": []int{1, 2}},
+			to:      map[string]any{"title": "a", "tags": []int{1, 2}},
+			patch:   `[]`,
+			summary: []string{},
+		},
+		{
+			name:    "changed field",
+			from:    map[string]any{"title": "a"},
+			to:      map[string]any{"title": "b"},
+			patch:   `[{"op":"replace","path":"/title","value":"b"}]`,
+			summary: []string{`changed /title from "a" to "b"`},
+		},
+		{
+			name:  "added and removed fields",
+			from:  map[string]any{"old": 1, "same": true},
+			to:    map[string]any{"new": 2, "same": true},
+			patch: `[{"op":"remove","path":"/old"},{"op":"add","path":"/new","value":2}]`,
+			summary: []string{
+				`removed /old (was 1)`,
+				`added /new = 2`,
+			},
+		},
+		{
+			name:    "nested object",
+			from:    map[string]any{"link": map[string]any{"url": "http://a", "target": "_blank"}},
+			to:      map[string]any{"link": map[string]any{"url": "http://b", "target": "_blank"}},
+			patch:   `[{"op":"replace","path":"/link/url","value":"http://b"}]`,
+			summary: []string{`changed /link/url from "http://a" to "http://b"`},
+		},
+		{
+			name:  "array grows and changes",
+			from:  map[string]any{"items": []any{"a", "b"}},
+			to:    map[string]any{"items": []any{"a", "c", "d"}},
+			patch: `[{"op":"replace","path":"/items/1","value":"c"},{"op":"add","path":"/items/2","value":"d"}]`,
+			summary: []string{
+				`changed /items/1 from "b" to "c"`,
+				`added /items/2 = "d"`,
+			},
+		},
+		{
+			name:  "array shrinks from the tail",
+			from:  []any{1, 2, 3},
+			to:    []any{1},
+			patch: `[{"op":"remove","path":"/2"},{"op":"remove","path":"/1"}]`,
+			summary: []string{
+				`removed /2 (was 3)`,
+				`removed /1 (was 2)`,
+			},
+		},
+		{
+			name:    "type change is a replace",
+			from:    map[string]any{"price": "10"},
+			to:      map[string]any{"price": map[string]any{"amount": 10}},
+			patch:   `[{"op":"replace","path":"/price","value":{"amount":10}}]`,
+			summary: []string{`changed /price from "10" to {"amount":10}`},
+		},
+		{
+			name:    "replace with null keeps the value",
+			from:    map[string]any{"image": "x.png"},
+			to:      map[string]any{"image": nil},
+			patch:   `[{"op":"replace","path":"/image","value":null}]`,
+			summary: []string{`changed /image from "x.png" to null`},
+		},
+		{
+			name:    "whole document",
+			from:    nil,
+			to:      map[string]any{"a": 1},
+			patch:   `[{"op":"replace","path":"","value":{"a":1}}]`,
+			summary: []string{`changed  from null to {"a":1}`},
+		},
+		{
+			name:    "keys are escaped",
+			from:    map[string]any{"a/b": 1, "c~d": 1},
+			to:      map[string]any{"a/b": 2, "c~d": 2},
+			patch:   `[{"op":"replace","path":"/a~1b","value":2},{"op":"replace","path":"/c~0d","value":2}]`,
+			summary: []string{`changed /a~1b from 1 to 2`, `changed /c~0d from 1 to 2`},
+		},
+		{
+			name:    "structs are compared by their JSON",
+			from:    struct{ Title string }{"a"},
+			to:      struct{ Title string }{"b"},
+			patch:   `[{"op":"replace","path":"/Title","value":"b"}]`,
+			summary: []string{`changed /Title from "a" to "b"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, err := Diff(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+
+			patch, err := json.Marshal(ops)
+			if err != nil {
+				t.Fatalf("marshaling patch: %v", err)
+			}
+			if string(patch) != tt.patch {
+				t.Errorf("Diff() patch = %s, want %s", patch, tt.patch)
+			}
+
+			if summary := Summary(ops); !reflect.DeepEqual(summary, tt.summary) {
+				t.Errorf("Summary() = %q, want %q", summary, tt.summary)
+			}
+		})
+	}
+}
+
+func TestDiffUnmarshalable(t *testing.T) {
+	if _, err := Diff(map[string]any{"f": func() {}}, nil); err == nil {
+		t.Error("Diff() error = nil, want an error for a value that is not JSON")
+	}
+}
@@ -0,0 +1,322 @@
+package migrate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var files embed.FS
+
+// lockID keys the advisory lock that keeps replicas from migrating at once.
+const lockID = 7364219
+
+var (
+	ErrNoChange       = errors.New("no change")
+	ErrSchemaOutdated = errors.New("schema is not at the expected version")
+	ErrUnknownVersion = errors.New("unknown migration version")
+)
+
+// Migration is one versioned schema change, read from a pair of
+// <version>_<name>.up.sql and <version>_<name>.down.sql files.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Current int64
+	Latest  int64
+	Pending []Migration
+}
+
+type Migrator struct {
+	db         *pgxpool.Pool
+	migrations []Migration
+}
+
+func New(db *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Latest is the schema version this binary expects.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	var applied int
+
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		current, err := version(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mg := range m.migrations {
+			if mg.Version <= current {
+				continue
+			}
+			if err := apply(ctx, conn, mg.Up, mg.Version); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mg.Version, mg.Name, err)
+			}
+			applied++
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the latest applied migration and returns the version the
+// schema is at afterwards.
+func (m *Migrator) Down(ctx context.Context) (int64, error) {
+	var target int64
+
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		current, err := version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			return ErrNoChange
+		}
+
+		i := m.index(current)
+		if i < 0 {
+			return fmt.Errorf("%w: %d", ErrUnknownVersion, current)
+		}
+		if i > 0 {
+			target = m.migrations[i-1].Version
+		}
+
+		mg := m.migrations[i]
+		if err := apply(ctx, conn, mg.Down, target); err != nil {
+			return fmt.Errorf("migration %d_%s down: %w", mg.Version, mg.Name, err)
+		}
+
+		return nil
+	})
+
+	return target, err
+}
+
+// Force records the schema as being at the given version without running
+// anything, to recover from a migration that was fixed up by hand.
+func (m *Migrator) Force(ctx context.Context, v int64) error {
+	if v != 0 && m.index(v) < 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, v)
+	}
+
+	return m.locked(ctx, func(conn *pgxpool.Conn) error {
+		return apply(ctx, conn, "", v)
+	})
+}
+
+func (m *Migrator) Status(ctx context.Context) (Status, error) {
+	current, err := version(ctx, m.db)
+	if err != nil {
+		return Status{}, err
+	}
+
+	return m.status(current), nil
+}
+
+func (m *Migrator) status(current int64) Status {
+	status := Status{Current: current, Latest: m.Latest()}
+	for _, mg := range m.migrations {
+		if mg.Version > current {
+			status.Pending = append(status.Pending, mg)
+		}
+	}
+
+	return status
+}
+
+// Check fails with ErrSchemaOutdated unless the database is exactly at the
+// version this binary expects.
+func (m *Migrator) Check(ctx context.Context) error {
+	current, err := version(ctx, m.db)
+	if err != nil {
+		return err
+	}
+
+	return m.check(current)
+}
+
+func (m *Migrator) check(current int64) error {
+	if current != m.Latest() {
+		return fmt.Errorf("%w: database is at %d, expected %d", ErrSchemaOutdated, current, m.Latest())
+	}
+
+	return nil
+}
+
+func (m *Migrator) index(v int64) int {
+	for i, mg := range m.migrations {
+		if mg.Version == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// locked runs fn on a single connection holding the migration lock.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+
+	const createQuery = "CREATE TABLE IF NOT EXISTS schema_migrations(version BIGINT NOT NULL)"
+	if _, err := conn.Exec(ctx, createQuery); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// version reads the current schema version, 0 for an empty database.
+func version(ctx context.Context, db querier) (int64, error) {
+	// The table does not exist before the first migration.
+	var exists bool
+	if err := db.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var v int64
+	if err := db.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&v); err != nil {
+		return 0, err
+	}
+
+	return v, nil
+}
+
+// apply runs sql and records the resulting version in one transaction, so a
+// failed migration leaves the schema as it was.
+func apply(ctx context.Context, conn *pgxpool.Conn, sql string, v int64) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if strings.TrimSpace(sql) != "" {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "INSERT INTO schema_migrations(version) VALUES ($1)", v); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, name := range names {
+		base := path.Base(name)
+
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql", base)
+		}
+
+		prefix, label, ok := strings.Cut(strings.TrimSuffix(base, "."+direction+".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>", base)
+		}
+		v, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", base)
+		}
+
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[v]
+		if !ok {
+			mg = &Migration{Version: v, Name: label}
+			byVersion[v] = mg
+		}
+		if mg.Name != label {
+			return nil, fmt.Errorf("migration %s: version %d is already used by %s", base, v, mg.Name)
+		}
+		if direction == "up" {
+			mg.Up = string(body)
+		} else {
+			mg.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" || mg.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: both up and down steps are required", mg.Version, mg.Name)
+		}
+		migrations = append(migrations, *mg)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	// A missing version is a file lost in a merge, and databases migrated
+	// with and without it would silently differ.
+	for i, mg := range migrations {
+		if mg.Version != int64(i+1) {
+			return nil, fmt.Errorf("migration %d_%s: expected version %d", mg.Version, mg.Name, i+1)
+		}
+	}
+
+	return migrations, nil
+}
@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func mapFS(names ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, name := range names {
+		fsys["migrations/"+name] = &fstest.MapFile{Data: []byte("SELECT 1; -- " + name)}
+	}
+	return fsys
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		fsys     fstest.MapFS
+		versions []int64
+		wantErr  bool
+	}{
+		{
+			name:     "pairs up and down steps in version order",
+			fsys:     mapFS("0002_tags.down.sql", "0001_init.up.sql", "0010_window.up.sql", "0002_tags.up.sql", "0001_init.down.sql", "0003_keys.up.sql", "0003_keys.down.sql", "0004_audit.up.sql", "0004_audit.down.sql", "0005_tenants.up.sql", "0005_tenants.down.sql", "0006_a.up.sql", "0006_a.down.sql", "0007_b.up.sql", "0007_b.down.sql", "0008_c.up.sql", "0008_c.down.sql", "0009_d.up.sql", "0009_d.down.sql", "0010_window.down.sql"),
+			versions: []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		},
+		{name: "empty", fsys: mapFS(), versions: []int64{}},
+		{name: "missing down step", fsys: mapFS("0001_init.up.sql"), wantErr: true},
+		{name: "missing up step", fsys: mapFS("0001_init.down.sql"), wantErr: true},
+		{name: "gap between versions", fsys: mapFS("0001_init.up.sql", "0001_init.down.sql", "0003_keys.up.sql", "0003_keys.down.sql"), wantErr: true},
+		{name: "not starting at 1", fsys: mapFS("0002_tags.up.sql", "0002_tags.down.sql"), wantErr: true},
+		{name: "two names for one version", fsys: mapFS("0001_init.up.sql", "0001_init.down.sql", "0001_other.up.sql", "0001_other.down.sql"), wantErr: true},
+		{name: "unknown direction", fsys: mapFS("0001_init.sql"), wantErr: true},
+		{name: "no name", fsys: mapFS("0001.up.sql", "0001.down.sql"), wantErr: true},
+		{name: "invalid version", fsys: mapFS("v1_init.up.sql", "v1_init.down.sql"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := load(tt.fsys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(migrations) != len(tt.versions) {
+				t.Fatalf("load() returned %d migrations, want %d", len(migrations), len(tt.versions))
+			}
+			for i, mg := range migrations {
+				if mg.Version != tt.versions[i] {
+					t.Errorf("migrations[%d].Version = %d, want %d", i, mg.Version, tt.versions[i])
+				}
+				if mg.Up == mg.Down || mg.Up == "" || mg.Down == "" {
+					t.Errorf("migration %d_%s steps are not paired: up %q, down %q", mg.Version, mg.Name, mg.Up, mg.Down)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Name != "init" {
+		t.Fatalf("first migration = %+v, want 0001_init", migrations)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	m := &Migrator{migrations: []Migration{{Version: 1, Name: "init"}, {Version: 2, Name: "tags"}, {Version: 3, Name: "keys"}}}
+
+	tests := []struct {
+		name    string
+		current int64
+		pending []int64
+		wantErr error
+	}{
+		{"empty database", 0, []int64{1, 2, 3}, ErrSchemaOutdated},
+		{"behind", 2, []int64{3}, ErrSchemaOutdated},
+		{"up to date", 3, nil, nil},
+		// A newer binary already migrated the database.
+		{"ahead", 4, nil, ErrSchemaOutdated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.check(tt.current); !errors.Is(err, tt.wantErr) {
+				t.Errorf("check(%d) error = %v, want %v", tt.current, err, tt.wantErr)
+			}
+
+			status := m.status(tt.current)
+			if status.Current != tt.current || status.Latest != 3 {
+				t.Errorf("status(%d) = %d/%d, want %d/3", tt.current, status.Current, status.Latest, tt.current)
+			}
+			if len(status.Pending) != len(tt.pending) {
+				t.Fatalf("status(%d) pending %d migrations, want %d", tt.current, len(status.Pending), len(tt.pending))
+			}
+			for i, mg := range status.Pending {
+				if mg.Version != tt.pending[i] {
+					t.Errorf("pending[%d] = %d, want %d", i, mg.Version, tt.pending[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLatestWithoutMigrations(t *testing.T) {
+	if latest := (&Migrator{}).Latest(); latest != 0 {
+		t.Errorf("Latest() = %d, want 0", latest)
+	}
+}
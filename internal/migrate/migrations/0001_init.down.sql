@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS banner_transitions;
+
+DROP TABLE IF EXISTS banner_version;
+
+DROP TABLE IF EXISTS banners;
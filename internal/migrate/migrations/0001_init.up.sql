@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS banners(
+    banner_id SERIAL PRIMARY KEY, 
+    feature INT NOT NULL, 
+    tags INT[] NOT NULL, 
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    start_at TIMESTAMPTZ, 
+    end_at TIMESTAMPTZ, 
+    CONSTRAINT unique_feature_tags UNIQUE (feature, tags),
+    CONSTRAINT valid_activation_window CHECK (start_at IS NULL OR end_at IS NULL OR start_at < end_at)
+);
+
+CREATE TABLE IF NOT EXISTS banner_version(
+    id SERIAL PRIMARY KEY, 
+    banner_id INT REFERENCES banners(banner_id) ON DELETE CASCADE, 
+    feature INT NOT NULL, 
+    tags INT[] NOT NULL, 
+    is_active BOOLEAN NOT NULL, 
+    status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'in_review', 'published')), 
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(), 
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    banner_info JSONB
+);
+
+CREATE TABLE IF NOT EXISTS banner_transitions(
+    id SERIAL PRIMARY KEY, 
+    banner_id INT NOT NULL REFERENCES banners(banner_id) ON DELETE CASCADE, 
+    action TEXT NOT NULL CHECK (action IN ('publish', 'expire')), 
+    run_at TIMESTAMPTZ NOT NULL, 
+    executed_at TIMESTAMPTZ, 
+    executed_by TEXT, 
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS banner_transitions_pending_idx ON banner_transitions(run_at) WHERE executed_at IS NULL;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS api_keys(
+    id SERIAL PRIMARY KEY, 
+    name TEXT NOT NULL, 
+    prefix TEXT NOT NULL, 
+    key_hash TEXT NOT NULL UNIQUE, 
+    role TEXT NOT NULL, 
+    scopes TEXT[] NOT NULL DEFAULT '{}', 
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(), 
+    expires_at TIMESTAMPTZ, 
+    rotated_at TIMESTAMPTZ, 
+    revoked_at TIMESTAMPTZ, 
+    last_used_at TIMESTAMPTZ
+);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS audit_log(
+    id BIGSERIAL PRIMARY KEY, 
+    actor TEXT NOT NULL, 
+    action TEXT NOT NULL, 
+    banner_id INT, 
+    feature INT, 
+    before JSONB, 
+    after JSONB, 
+    request_id TEXT, 
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log(actor, id);
+CREATE INDEX IF NOT EXISTS audit_log_banner_idx ON audit_log(banner_id, id);
+CREATE INDEX IF NOT EXISTS audit_log_feature_idx ON audit_log(feature, id);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log(created_at);
+
+-- Entries are append-only: neither the service nor anyone with SQL access
+-- may rewrite history.
+CREATE OR REPLACE FUNCTION audit_log_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_immutable ON audit_log;
+CREATE TRIGGER audit_log_immutable BEFORE UPDATE OR DELETE ON audit_log 
+    FOR EACH ROW EXECUTE FUNCTION audit_log_immutable();
@@ -0,0 +1,10 @@
+-- Fails if two tenants have a banner with the same feature and tags.
+DROP INDEX IF EXISTS audit_log_tenant_idx;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS tenant;
+
+DROP INDEX IF EXISTS api_keys_tenant_idx;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant;
+
+ALTER TABLE banners DROP CONSTRAINT IF EXISTS unique_tenant_feature_tags;
+ALTER TABLE banners ADD CONSTRAINT unique_feature_tags UNIQUE (feature, tags);
+ALTER TABLE banners DROP COLUMN IF EXISTS tenant;
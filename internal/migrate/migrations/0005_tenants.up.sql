@@ -0,0 +1,10 @@
+-- Rows that existed before tenants belong to the default one.
+ALTER TABLE banners ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE banners DROP CONSTRAINT IF EXISTS unique_feature_tags;
+ALTER TABLE banners ADD CONSTRAINT unique_tenant_feature_tags UNIQUE (tenant, feature, tags);
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS api_keys_tenant_idx ON api_keys(tenant, id);
+
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS audit_log_tenant_idx ON audit_log(tenant, id);
@@ -0,0 +1,3 @@
+-- Revisions are pruned by the configurable retention policy now.
+DROP TRIGGER IF EXISTS banner_version_row_limit_trigger ON banner_version;
+DROP FUNCTION IF EXISTS maintain_banner_version_rows();
@@ -0,0 +1,3 @@
+ALTER TABLE banner_version DROP COLUMN IF EXISTS is_active;
+ALTER TABLE banner_version DROP COLUMN IF EXISTS tags;
+ALTER TABLE banner_version DROP COLUMN IF EXISTS feature;
@@ -0,0 +1,20 @@
+-- Revisions keep the feature, tags and flag they were written with, so they
+-- can be diffed and restored as a whole.
+ALTER TABLE banner_version ADD COLUMN IF NOT EXISTS feature INT;
+ALTER TABLE banner_version ADD COLUMN IF NOT EXISTS tags INT[];
+ALTER TABLE banner_version ADD COLUMN IF NOT EXISTS is_active BOOLEAN;
+
+UPDATE banner_version bv
+SET 
+    feature = b.feature, 
+    tags = b.tags, 
+    is_active = b.is_active
+FROM banners b
+WHERE b.banner_id = bv.banner_id AND bv.feature IS NULL;
+
+-- Revisions without a banner were never reachable.
+DELETE FROM banner_version WHERE feature IS NULL;
+
+ALTER TABLE banner_version ALTER COLUMN feature SET NOT NULL;
+ALTER TABLE banner_version ALTER COLUMN tags SET NOT NULL;
+ALTER TABLE banner_version ALTER COLUMN is_active SET NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE banner_version DROP COLUMN IF EXISTS status;
@@ -0,0 +1,5 @@
+-- Existing revisions were all live, so they count as published.
+ALTER TABLE banner_version ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'published';
+
+ALTER TABLE banner_version DROP CONSTRAINT IF EXISTS banner_version_status_check;
+ALTER TABLE banner_version ADD CONSTRAINT banner_version_status_check CHECK (status IN ('draft', 'in_review', 'published'));
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS banner_transitions;
+
+ALTER TABLE banners DROP CONSTRAINT IF EXISTS valid_activation_window;
+ALTER TABLE banners DROP COLUMN IF EXISTS end_at;
+ALTER TABLE banners DROP COLUMN IF EXISTS start_at;
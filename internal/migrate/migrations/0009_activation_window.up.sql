@@ -0,0 +1,17 @@
+ALTER TABLE banners ADD COLUMN IF NOT EXISTS start_at TIMESTAMPTZ;
+ALTER TABLE banners ADD COLUMN IF NOT EXISTS end_at TIMESTAMPTZ;
+
+ALTER TABLE banners DROP CONSTRAINT IF EXISTS valid_activation_window;
+ALTER TABLE banners ADD CONSTRAINT valid_activation_window CHECK (start_at IS NULL OR end_at IS NULL OR start_at < end_at);
+
+CREATE TABLE IF NOT EXISTS banner_transitions(
+    id SERIAL PRIMARY KEY, 
+    banner_id INT NOT NULL REFERENCES banners(banner_id) ON DELETE CASCADE, 
+    action TEXT NOT NULL CHECK (action IN ('publish', 'expire')), 
+    run_at TIMESTAMPTZ NOT NULL, 
+    executed_at TIMESTAMPTZ, 
+    executed_by TEXT, 
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS banner_transitions_pending_idx ON banner_transitions(run_at) WHERE executed_at IS NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE banner_version DROP COLUMN IF EXISTS end_at;
+ALTER TABLE banner_version DROP COLUMN IF EXISTS start_at;
@@ -0,0 +1,11 @@
+-- Edits go through review as a whole, so revisions also keep the activation
+-- window they were written with.
+ALTER TABLE banner_version ADD COLUMN IF NOT EXISTS start_at TIMESTAMPTZ;
+ALTER TABLE banner_version ADD COLUMN IF NOT EXISTS end_at TIMESTAMPTZ;
+
+UPDATE banner_version bv
+SET 
+    start_at = b.start_at, 
+    end_at = b.end_at
+FROM banners b
+WHERE b.banner_id = bv.banner_id;
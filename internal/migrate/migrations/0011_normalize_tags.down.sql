@@ -0,0 +1,2 @@
+-- The original order of the tags is not kept, normalizing cannot be undone.
+SELECT 1;
@@ -0,0 +1,49 @@
+-- Tags are looked up sorted and without duplicates, so rows written before
+-- the service normalized them could never be found. Normalizing may make two
+-- banners of a feature collide on the unique constraint: the one whose tags
+-- are already normalized (or else the oldest) wins, the others keep their
+-- tags, are deactivated and reported so they can be merged by hand.
+DO $$
+DECLARE
+    collision RECORD;
+    losers INT[] := '{}';
+BEGIN
+    FOR collision IN
+        SELECT banner_id, tenant, feature, tags
+        FROM (
+            SELECT 
+                banner_id, 
+                tenant, 
+                feature, 
+                tags,
+                ROW_NUMBER() OVER (
+                    PARTITION BY tenant, feature, ARRAY(SELECT DISTINCT t FROM unnest(tags) AS t ORDER BY t)
+                    ORDER BY tags = ARRAY(SELECT DISTINCT t FROM unnest(tags) AS t ORDER BY t) DESC, banner_id
+                ) AS rn
+            FROM banners
+        ) AS b
+        WHERE b.rn > 1
+    LOOP
+        RAISE NOTICE 'banner % (tenant %, feature %, tags %) collides with another banner once its tags are normalized, it is deactivated',
+            collision.banner_id, collision.tenant, collision.feature, collision.tags;
+        losers := losers || collision.banner_id;
+    END LOOP;
+
+    UPDATE banners 
+    SET 
+        is_active = false, 
+        revision = revision + 1
+    WHERE banner_id = ANY(losers);
+
+    UPDATE banners
+    SET 
+        tags = ARRAY(SELECT DISTINCT t FROM unnest(tags) AS t ORDER BY t), 
+        revision = revision + 1
+    WHERE 
+        banner_id <> ALL(losers) AND 
+        tags <> ARRAY(SELECT DISTINCT t FROM unnest(tags) AS t ORDER BY t);
+END $$;
+
+UPDATE banner_version
+SET tags = ARRAY(SELECT DISTINCT t FROM unnest(tags) AS t ORDER BY t)
+WHERE tags <> ARRAY(SELECT DISTINCT t FROM unnest(tags) AS t ORDER BY t);
@@ -0,0 +1,2 @@
+-- Both versions of 0001 end up with this schema, there is nothing to revert.
+SELECT 1;
@@ -0,0 +1,15 @@
+-- For a while 0001 shipped as the bare init.sql schema, with the retention
+-- trigger recreated and the later columns added by 0006-0009. Databases
+-- migrated by either version of 0001 reach the same schema here: the
+-- retention function is gone and the defaults and checks are in place.
+DROP TRIGGER IF EXISTS banner_version_row_limit_trigger ON banner_version;
+DROP FUNCTION IF EXISTS maintain_banner_version_rows();
+
+ALTER TABLE banners ALTER COLUMN is_active SET DEFAULT TRUE;
+ALTER TABLE banner_version ALTER COLUMN status SET DEFAULT 'published';
+
+ALTER TABLE banner_version DROP CONSTRAINT IF EXISTS banner_version_status_check;
+ALTER TABLE banner_version ADD CONSTRAINT banner_version_status_check CHECK (status IN ('draft', 'in_review', 'published'));
+
+ALTER TABLE banners DROP CONSTRAINT IF EXISTS valid_activation_window;
+ALTER TABLE banners ADD CONSTRAINT valid_activation_window CHECK (start_at IS NULL OR end_at IS NULL OR start_at < end_at);
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+type apiKeyRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAPIKeyRepo(db *pgxpool.Pool) *apiKeyRepo {
+	return &apiKeyRepo{db}
+}
+
+func (r *apiKeyRepo) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, r.db, fn)
+}
+
+func (r *apiKeyRepo) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+const selectAPIKeyColumns = `
+	SELECT
+		id,
+		tenant,
+		name,
+		prefix,
+		role,
+		scopes,
+		created_at,
+		expires_at,
+		rotated_at,
+		revoked_at,
+		last_used_at
+	FROM
+		api_keys`
+
+func scanAPIKey(row pgx.Row) (domain.APIKey, error) {
+	var k domain.APIKey
+	err := row.Scan(&k.ID, &k.Tenant, &k.Name, &k.Prefix, &k.Role, &k.Scopes, &k.CreatedAt, &k.ExpiresAt, &k.RotatedAt, &k.RevokedAt, &k.LastUsedAt)
+	return k, err
+}
+
+// InsertAPIKey stores a key of the tenant of ctx.
+func (r *apiKeyRepo) InsertAPIKey(ctx context.Context, key domain.APIKey, hash string) (domain.APIKey, error) {
+	const op = "repository.postgres.InsertAPIKey"
+
+	const query = `
+	INSERT INTO api_keys(name, prefix, key_hash, role, scopes, expires_at, tenant)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, tenant, created_at`
+
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+
+	err := r.conn(ctx).QueryRow(ctx, query, key.Name, key.Prefix, hash, key.Role, key.Scopes, key.ExpiresAt, tenant.FromContext(ctx)).Scan(&key.ID, &key.Tenant, &key.CreatedAt)
+	if err != nil {
+		return domain.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+func (r *apiKeyRepo) GetAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	const op = "repository.postgres.GetAPIKeys"
+
+	rows, err := r.conn(ctx).Query(ctx, selectAPIKeyColumns+" WHERE tenant = $1 ORDER BY id", tenant.FromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []domain.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// GetAPIKeyByHash finds a key of any tenant: it is how the tenant of a
+// request is found out in the first place.
+func (r *apiKeyRepo) GetAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	const op = "repository.postgres.GetAPIKeyByHash"
+
+	k, err := scanAPIKey(r.conn(ctx).QueryRow(ctx, selectAPIKeyColumns+" WHERE key_hash = $1", hash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.APIKey{}, ErrAPIKeyNotFound
+		}
+		return domain.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return k, nil
+}
+
+// RotateAPIKey replaces the secret of a key that is not revoked. The old
+// secret stops working right away.
+func (r *apiKeyRepo) RotateAPIKey(ctx context.Context, id int64, prefix string, hash string) (domain.APIKey, error) {
+	const op = "repository.postgres.RotateAPIKey"
+
+	const query = `
+	UPDATE
+		api_keys
+		SET
+			prefix = $2,
+			key_hash = $3,
+			rotated_at = NOW()
+		WHERE
+			id = $1 AND tenant = $4 AND revoked_at IS NULL
+	RETURNING id, tenant, name, prefix, role, scopes, created_at, expires_at, rotated_at, revoked_at, last_used_at`
+
+	k, err := scanAPIKey(r.conn(ctx).QueryRow(ctx, query, id, prefix, hash, tenant.FromContext(ctx)))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.APIKey{}, ErrAPIKeyNotFound
+		}
+		return domain.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return k, nil
+}
+
+func (r *apiKeyRepo) RevokeAPIKey(ctx context.Context, id int64) error {
+	const op = "repository.postgres.RevokeAPIKey"
+
+	const query = "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1 AND tenant = $2"
+
+	tag, err := r.conn(ctx).Exec(ctx, query, id, tenant.FromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// TouchAPIKeys records when keys were last used, for many keys at once.
+// Timestamps never move backwards.
+func (r *apiKeyRepo) TouchAPIKeys(ctx context.Context, uses map[int64]time.Time) error {
+	const op = "repository.postgres.TouchAPIKeys"
+
+	const query = `
+	UPDATE
+		api_keys
+		SET
+			last_used_at = u.used_at
+		FROM 
+			unnest($1::bigint[], $2::timestamptz[]) AS u(id, used_at)
+		WHERE
+			api_keys.id = u.id AND (api_keys.last_used_at IS NULL OR api_keys.last_used_at < u.used_at)`
+
+	ids := make([]int64, 0, len(uses))
+	times := make([]time.Time, 0, len(uses))
+	for id, t := range uses {
+		ids = append(ids, id)
+		times = append(times, t)
+	}
+
+	if _, err := r.conn(ctx).Exec(ctx, query, ids, times); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
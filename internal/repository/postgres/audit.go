@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+type auditRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditRepo(db *pgxpool.Pool) *auditRepo {
+	return &auditRepo{db}
+}
+
+// InsertAuditEntry appends an entry to the audit log of the tenant of ctx.
+// Called with the context of a transaction, the entry is written with the
+// change itself.
+func (r *auditRepo) InsertAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	const op = "repository.postgres.InsertAuditEntry"
+
+	if err := insertAuditEntry(ctx, conn(ctx, r.db), entry); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func insertAuditEntry(ctx context.Context, db querier, entry domain.AuditEntry) error {
+	const query = `
+	INSERT INTO audit_log(actor, action, banner_id, feature, before, after, request_id, tenant)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := db.Exec(ctx, query, entry.Actor, entry.Action, entry.BannerID, entry.FeatureID, nullJSON(entry.Before), nullJSON(entry.After), entry.RequestID, tenant.FromContext(ctx))
+	return err
+}
+
+// GetAuditEntries returns up to filter.Limit entries matching the filter,
+// newest first, starting below filter.Cursor if it is set.
+func (r *auditRepo) GetAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	const op = "repository.postgres.GetAuditEntries"
+
+	var (
+		conditions []string
+		args       []any
+	)
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	where("tenant = $%d", tenant.FromContext(ctx))
+	if filter.Actor != "" {
+		where("actor = $%d", filter.Actor)
+	}
+	if filter.BannerID != 0 {
+		where("banner_id = $%d", filter.BannerID)
+	}
+	if filter.FeatureID != 0 {
+		where("feature = $%d", filter.FeatureID)
+	}
+	if filter.From != nil {
+		where("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("created_at < $%d", *filter.To)
+	}
+	if filter.Cursor != 0 {
+		where("id < $%d", filter.Cursor)
+	}
+	if filter.Features != nil {
+		from := make([]int64, 0, len(filter.Features))
+		to := make([]int64, 0, len(filter.Features))
+		for _, r := range filter.Features {
+			from = append(from, r.From)
+			to = append(to, r.To)
+		}
+		args = append(args, from, to)
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM unnest($%d::bigint[], $%d::bigint[]) AS r(low, high) WHERE feature BETWEEN r.low AND r.high)", len(args)-1, len(args)))
+	}
+
+	query := `
+	SELECT 
+		id, 
+		actor, 
+		action, 
+		banner_id, 
+		feature, 
+		before, 
+		after, 
+		COALESCE(request_id, ''), 
+		created_at 
+	FROM 
+		audit_log`
+	query += "\n\tWHERE\n\t\t" + strings.Join(conditions, " AND ")
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf("\n\tORDER BY\n\t\tid DESC\n\tLIMIT $%d", len(args))
+
+	rows, err := conn(ctx, r.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var entries []domain.AuditEntry
+	for rows.Next() {
+		var e domain.AuditEntry
+		var before, after []byte
+		err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.BannerID, &e.FeatureID, &before, &after, &e.RequestID, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// nullJSON stores a missing snapshot as NULL rather than as JSON null.
+func nullJSON(raw []byte) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
@@ -2,74 +2,82 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/tenant"
 )
 
-var ctx = context.Background()
-
 type bannerRepo struct {
-	db *pgx.Conn
+	db *pgxpool.Pool
 }
 
-func NewBannerRepo(db *pgx.Conn) *bannerRepo {
+func NewBannerRepo(db *pgxpool.Pool) *bannerRepo {
 	return &bannerRepo{db}
 }
 
-func (r *bannerRepo) InsertBanner(banner domain.Banner) (int64, error) {
+// InsertBanner stores a banner of the tenant of ctx. Like every method of
+// bannerRepo, it never touches banners of other tenants.
+func (r *bannerRepo) InsertBanner(ctx context.Context, banner domain.Banner) (int64, error) {
 	const op = "repository.postgres.InsertBanner"
 
-	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{})
-	if err != nil {
-		return -1, fmt.Errorf("%s: %w", op, err)
-	}
-	defer tx.Rollback(ctx)
-
-	const insertBannerQuery = "INSERT INTO banners(feature, tags, is_active) VALUES ($1, $2, $3) RETURNING banner_id"
+	const insertBannerQuery = "INSERT INTO banners(feature, tags, is_active, start_at, end_at, tenant) VALUES ($1, $2, $3, $4, $5, $6) RETURNING banner_id"
+	const insertBannerVersionQuery = "INSERT INTO banner_version(banner_id, banner_info, feature, tags, is_active, start_at, end_at, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
 
 	var bannerID int64
-	if err := r.db.QueryRow(ctx, insertBannerQuery, banner.FeatureID, banner.TagIds, banner.IsActive).Scan(&bannerID); err != nil {
-		return -1, fmt.Errorf("%s: %w", op, err)
-	}
+	err := r.WithinTx(ctx, func(ctx context.Context) error {
+		db := r.conn(ctx)
 
-	const insertBannerVersionQuery = "INSERT INTO banner_version(banner_id, banner_info) VALUES ($1, $2)"
-	if _, err := r.db.Exec(ctx, insertBannerVersionQuery, bannerID, banner.Content); err != nil {
-		return -1, fmt.Errorf("%s: %w", op, err)
-	}
+		if err := db.QueryRow(ctx, insertBannerQuery, banner.FeatureID, banner.TagIds, banner.IsActive, banner.StartAt, banner.EndAt, tenant.FromContext(ctx)).Scan(&bannerID); err != nil {
+			return err
+		}
 
-	if err := tx.Commit(ctx); err != nil {
+		_, err := db.Exec(ctx, insertBannerVersionQuery, bannerID, banner.Content, banner.FeatureID, banner.TagIds, banner.IsActive, banner.StartAt, banner.EndAt, domain.VersionPublished)
+		return err
+	})
+	if err != nil {
 		return -1, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return bannerID, nil
 }
 
-func (r *bannerRepo) GetBanners(tagIDs []int64, featureID int64, limit int64, offset int64) ([]domain.Banner, error) {
+func (r *bannerRepo) GetBanners(ctx context.Context, tagIDs []int64, featureID int64, limit int64, offset int64) ([]domain.Banner, error) {
 	const op = "repository.postgres.GetBanners"
 
 	const selectBannersByFeatureAndTagsQuery = `
 	SELECT 
 		b.banner_id, 
+		b.tenant, 
 		b.feature, 
 		b.tags, 
 		b.is_active, 
+		b.start_at, 
+		b.end_at, 
+		b.revision, 
 		bv.banner_info, 
 		bv.created_at, 
 		bv.updated_at 
 	FROM 
 		banners as b
-	JOIN banner_version as bv
-	ON 
-		b.banner_id = bv.banner_id 
+	JOIN LATERAL (
+		SELECT banner_info, created_at, updated_at
+		FROM banner_version
+		WHERE banner_id = b.banner_id AND status = 'published'
+		ORDER BY updated_at DESC, id DESC
+		LIMIT 1
+	) AS bv ON TRUE
 	WHERE 
-		b.feature = $1 AND tags @> $2
+		b.tenant = $5 AND b.feature = $1 AND tags @> $2
 	ORDER BY
-		b.banner_id, bv.created_at, bv.updated_at
+		b.banner_id
 	LIMIT $3 OFFSET $4`
 
-	rows, err := r.db.Query(ctx, selectBannersByFeatureAndTagsQuery, featureID, tagIDs, limit, offset)
+	rows, err := r.conn(ctx).Query(ctx, selectBannersByFeatureAndTagsQuery, featureID, tagIDs, limit, offset, tenant.FromContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -78,7 +86,7 @@ func (r *bannerRepo) GetBanners(tagIDs []int64, featureID int64, limit int64, of
 	var banners []domain.Banner
 	for rows.Next() {
 		var b domain.Banner
-		err := rows.Scan(&b.BannerID, &b.FeatureID, &b.TagIds, &b.IsActive, &b.Content, &b.CreatedAt, &b.UpdatedAt)
+		err := rows.Scan(&b.BannerID, &b.Tenant, &b.FeatureID, &b.TagIds, &b.IsActive, &b.StartAt, &b.EndAt, &b.Revision, &b.Content, &b.CreatedAt, &b.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
@@ -88,33 +96,44 @@ func (r *bannerRepo) GetBanners(tagIDs []int64, featureID int64, limit int64, of
 	return banners, nil
 }
 
-func (r *bannerRepo) GetBanner(tagIDs []int64, featureID int64, IsAdmin bool) ([]domain.Banner, error) {
+func (r *bannerRepo) GetBanner(ctx context.Context, tagIDs []int64, featureID int64, IsAdmin bool) ([]domain.Banner, error) {
 	const op = "repository.postgres.GetBannerByFeatureIDandTagsId"
 
 	selectBanner := `
 	SELECT 
 		b.banner_id, 
+		b.tenant, 
 		b.feature, 
 		b.tags, 
 		b.is_active, 
+		b.start_at, 
+		b.end_at, 
+		b.revision, 
 		bv.banner_info, 
 		bv.created_at, 
 		bv.updated_at 
 	FROM 
 		banners as b
-	JOIN banner_version as bv
-	ON 
-		b.banner_id = bv.banner_id 
+	JOIN LATERAL (
+		SELECT banner_info, created_at, updated_at
+		FROM banner_version
+		WHERE banner_id = b.banner_id AND status = 'published'
+		ORDER BY updated_at DESC, id DESC
+		LIMIT 1
+	) AS bv ON TRUE
 	WHERE
-		b.feature = $1 AND tags = $2`
+		b.tenant = $3 AND b.feature = $1 AND tags = $2`
 
 	if !IsAdmin {
-		selectBanner += " AND b.is_active IS NOT FALSE"
+		selectBanner += `
+		AND b.is_active IS NOT FALSE
+		AND (b.start_at IS NULL OR b.start_at <= NOW())
+		AND (b.end_at IS NULL OR b.end_at > NOW())`
 	}
 
-	selectBanner += " ORDER BY b.banner_id, bv.created_at"
+	selectBanner += " ORDER BY b.banner_id"
 
-	rows, err := r.db.Query(ctx, selectBanner, featureID, tagIDs)
+	rows, err := r.conn(ctx).Query(ctx, selectBanner, featureID, tagIDs, tenant.FromContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -123,7 +142,7 @@ func (r *bannerRepo) GetBanner(tagIDs []int64, featureID int64, IsAdmin bool) ([
 	var banners []domain.Banner
 	for rows.Next() {
 		var b domain.Banner
-		err := rows.Scan(&b.BannerID, &b.FeatureID, &b.TagIds, &b.IsActive, &b.Content, &b.CreatedAt, &b.UpdatedAt)
+		err := rows.Scan(&b.BannerID, &b.Tenant, &b.FeatureID, &b.TagIds, &b.IsActive, &b.StartAt, &b.EndAt, &b.Revision, &b.Content, &b.CreatedAt, &b.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
@@ -133,67 +152,420 @@ func (r *bannerRepo) GetBanner(tagIDs []int64, featureID int64, IsAdmin bool) ([
 	return banners, nil
 }
 
-func (r *bannerRepo) UpdateBannerById(banner domain.Banner) error {
-	const op = "repository.postgres.UpdateBannerById"
+// GetLiveBanners returns every banner currently shown to users, with its
+// published content. It spans all tenants: it feeds the cache warm-up,
+// which fills the shared cache for the whole deployment.
+func (r *bannerRepo) GetLiveBanners(ctx context.Context) ([]domain.Banner, error) {
+	const op = "repository.postgres.GetLiveBanners"
 
-	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{})
+	const query = `
+	SELECT 
+		b.banner_id, 
+		b.tenant, 
+		b.feature, 
+		b.tags, 
+		b.is_active, 
+		b.start_at, 
+		b.end_at, 
+		b.revision, 
+		bv.banner_info, 
+		bv.created_at, 
+		bv.updated_at 
+	FROM 
+		banners as b
+	JOIN LATERAL (
+		SELECT banner_info, created_at, updated_at
+		FROM banner_version
+		WHERE banner_id = b.banner_id AND status = 'published'
+		ORDER BY updated_at DESC, id DESC
+		LIMIT 1
+	) AS bv ON TRUE
+	WHERE
+		b.is_active IS NOT FALSE
+		AND (b.start_at IS NULL OR b.start_at <= NOW())
+		AND (b.end_at IS NULL OR b.end_at > NOW())
+	ORDER BY 
+		b.banner_id`
+
+	rows, err := r.conn(ctx).Query(ctx, query)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var banners []domain.Banner
+	for rows.Next() {
+		var b domain.Banner
+		err := rows.Scan(&b.BannerID, &b.Tenant, &b.FeatureID, &b.TagIds, &b.IsActive, &b.StartAt, &b.EndAt, &b.Revision, &b.Content, &b.CreatedAt, &b.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		banners = append(banners, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	defer tx.Rollback(ctx)
+
+	return banners, nil
+}
+
+// UpdateBannerById stores an edit of a banner as its pending revision. The
+// banner itself only moves to the next revision: the edited fields reach it
+// once the revision is approved.
+func (r *bannerRepo) UpdateBannerById(ctx context.Context, banner domain.Banner) error {
+	const op = "repository.postgres.UpdateBannerById"
 
 	const queryBanner = `
 		UPDATE 
 			banners
 			SET 
-				feature = $1,
-				tags = $2,
-				is_active = $3
+				revision = revision + 1
 			WHERE 
-				banner_id = $4`
-
-	_, err = r.db.Exec(ctx, queryBanner, banner.FeatureID, banner.TagIds, banner.IsActive, banner.BannerID)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
+				banner_id = $1 AND revision = $2 AND tenant = $3`
 
+	// Edits never touch the published revision: they go into the banner's
+	// single pending revision, which is sent back to draft.
 	const queryBannerVersion = `
 		UPDATE 
 			banner_version
 			SET
 				banner_info = $1,
+				feature = $2,
+				tags = $3,
+				is_active = $4,
+				start_at = $5,
+				end_at = $6,
+				status = $7,
 				updated_at = NOW()
 			WHERE
-				id = (SELECT id
-					FROM banner_version
-					WHERE banner_id = $2
-					GROUP BY id
-					ORDER BY max(updated_at) DESC
-					LIMIT 1)
+				banner_id = $8 AND status IN ($7, $9)
 	`
-	_, err = r.db.Exec(ctx, queryBannerVersion, banner.Content, banner.BannerID)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
+	const insertBannerVersionQuery = "INSERT INTO banner_version(banner_id, banner_info, feature, tags, is_active, start_at, end_at, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+
+	err := r.WithinTx(ctx, func(ctx context.Context) error {
+		db := r.conn(ctx)
+
+		tag, err := db.Exec(ctx, queryBanner, banner.BannerID, banner.Revision, tenant.FromContext(ctx))
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return r.revisionMismatch(ctx, banner.BannerID)
+		}
 
-	if err := tx.Commit(ctx); err != nil {
+		tag, err = db.Exec(ctx, queryBannerVersion, banner.Content, banner.FeatureID, banner.TagIds, banner.IsActive, banner.StartAt, banner.EndAt, domain.VersionDraft, banner.BannerID, domain.VersionInReview)
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			_, err = db.Exec(ctx, insertBannerVersionQuery, banner.BannerID, banner.Content, banner.FeatureID, banner.TagIds, banner.IsActive, banner.StartAt, banner.EndAt, domain.VersionDraft)
+		}
+		return err
+	})
+	if errors.Is(err, ErrBannerNotFound) || errors.Is(err, ErrBannerRevisionStale) {
+		return err
+	}
+	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
-func (r *bannerRepo) DeleteBannerById(bannerID int64) error {
+// DeleteBannerById deletes a banner if it is still at the given revision.
+func (r *bannerRepo) DeleteBannerById(ctx context.Context, bannerID int64, revision int64) error {
 	const op = "repository.postgres.DeleteBannerById"
 
 	const query = `
 		DELETE FROM banners
-		WHERE banners.banner_id = $1
+		WHERE banners.banner_id = $1 AND banners.revision = $2 AND banners.tenant = $3
 	`
-	_, err := r.db.Exec(ctx, query, bannerID)
+	tag, err := r.conn(ctx).Exec(ctx, query, bannerID, revision, tenant.FromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return r.revisionMismatch(ctx, bannerID)
+	}
+
+	return nil
+}
+
+// revisionMismatch explains why a conditional write matched no row: either
+// the banner is gone or it moved past the expected revision.
+func (r *bannerRepo) revisionMismatch(ctx context.Context, bannerID int64) error {
+	const op = "repository.postgres.revisionMismatch"
+
+	var exists bool
+	if err := r.conn(ctx).QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM banners WHERE banner_id = $1 AND tenant = $2)", bannerID, tenant.FromContext(ctx)).Scan(&exists); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !exists {
+		return ErrBannerNotFound
+	}
+	return ErrBannerRevisionStale
+}
+
+func (r *bannerRepo) GetBannerByID(ctx context.Context, bannerID int64) (domain.Banner, error) {
+	const op = "repository.postgres.GetBannerByID"
+
+	const query = `
+	SELECT 
+		b.banner_id, 
+		b.tenant, 
+		b.feature, 
+		b.tags, 
+		b.is_active, 
+		b.start_at, 
+		b.end_at, 
+		b.revision, 
+		bv.banner_info, 
+		bv.created_at, 
+		bv.updated_at 
+	FROM 
+		banners as b
+	JOIN LATERAL (
+		SELECT banner_info, created_at, updated_at
+		FROM banner_version
+		WHERE banner_id = b.banner_id AND status = 'published'
+		ORDER BY updated_at DESC, id DESC
+		LIMIT 1
+	) AS bv ON TRUE
+	WHERE
+		b.banner_id = $1 AND b.tenant = $2`
+
+	var b domain.Banner
+	err := r.conn(ctx).QueryRow(ctx, query, bannerID, tenant.FromContext(ctx)).Scan(&b.BannerID, &b.Tenant, &b.FeatureID, &b.TagIds, &b.IsActive, &b.StartAt, &b.EndAt, &b.Revision, &b.Content, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.Banner{}, ErrBannerNotFound
+		}
+		return domain.Banner{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return b, nil
+}
+
+func (r *bannerRepo) GetBannerVersions(ctx context.Context, bannerID int64) ([]domain.BannerVersion, error) {
+	const op = "repository.postgres.GetBannerVersions"
+
+	const query = `
+	SELECT 
+		id, 
+		banner_id, 
+		feature, 
+		tags, 
+		is_active, 
+		start_at, 
+		end_at, 
+		status, 
+		banner_info, 
+		created_at, 
+		updated_at 
+	FROM 
+		banner_version
+	WHERE 
+		banner_id = $1 AND banner_id IN (SELECT banner_id FROM banners WHERE tenant = $2)
+	ORDER BY 
+		updated_at DESC, id DESC`
+
+	rows, err := r.conn(ctx).Query(ctx, query, bannerID, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var versions []domain.BannerVersion
+	for rows.Next() {
+		var v domain.BannerVersion
+		err := rows.Scan(&v.VersionID, &v.BannerID, &v.FeatureID, &v.TagIds, &v.IsActive, &v.StartAt, &v.EndAt, &v.Status, &v.Content, &v.CreatedAt, &v.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range versions {
+		if versions[i].Status == domain.VersionPublished {
+			versions[i].Active = true
+			break
+		}
+	}
+
+	return versions, nil
+}
+
+func (r *bannerRepo) GetBannerVersion(ctx context.Context, bannerID int64, versionID int64) (domain.BannerVersion, error) {
+	const op = "repository.postgres.GetBannerVersion"
+
+	const query = `
+	SELECT 
+		id, 
+		banner_id, 
+		feature, 
+		tags, 
+		is_active, 
+		start_at, 
+		end_at, 
+		status, 
+		banner_info, 
+		created_at, 
+		updated_at 
+	FROM 
+		banner_version
+	WHERE 
+		banner_id = $1 AND id = $2 AND banner_id IN (SELECT banner_id FROM banners WHERE tenant = $3)`
+
+	var v domain.BannerVersion
+	err := r.conn(ctx).QueryRow(ctx, query, bannerID, versionID, tenant.FromContext(ctx)).Scan(&v.VersionID, &v.BannerID, &v.FeatureID, &v.TagIds, &v.IsActive, &v.StartAt, &v.EndAt, &v.Status, &v.Content, &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.BannerVersion{}, ErrBannerVersionNotFound
+		}
+		return domain.BannerVersion{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return v, nil
+}
+
+func (r *bannerRepo) InsertBannerVersion(ctx context.Context, bannerID int64, content any) (int64, error) {
+	const op = "repository.postgres.InsertBannerVersion"
+
+	const query = `
+	INSERT INTO banner_version(banner_id, banner_info, feature, tags, is_active, start_at, end_at, status)
+	SELECT banner_id, $2, feature, tags, is_active, start_at, end_at, $3
+	FROM banners
+	WHERE banner_id = $1 AND tenant = $4
+	RETURNING id`
+
+	var versionID int64
+	err := r.WithinTx(ctx, func(ctx context.Context) error {
+		if err := r.conn(ctx).QueryRow(ctx, query, bannerID, content, domain.VersionPublished, tenant.FromContext(ctx)).Scan(&versionID); err != nil {
+			return err
+		}
+
+		// The published content is part of the banner, so it moves on.
+		return r.bumpRevision(ctx, bannerID)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return -1, ErrBannerNotFound
+		}
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return versionID, nil
+}
+
+// PruneBannerVersions deletes the published revisions of a banner that fall
+// outside of the retention limits. The active (newest published) revision and
+// pending revisions are never deleted.
+func (r *bannerRepo) PruneBannerVersions(ctx context.Context, bannerID int64, keep int, before *time.Time) (int64, error) {
+	const op = "repository.postgres.PruneBannerVersions"
+
+	const query = `
+	DELETE FROM banner_version
+	WHERE id IN (
+		SELECT id FROM (
+			SELECT 
+				id, 
+				updated_at, 
+				ROW_NUMBER() OVER (ORDER BY updated_at DESC, id DESC) AS rn
+			FROM 
+				banner_version
+			WHERE 
+				banner_id = $1 AND status = $4 AND 
+				banner_id IN (SELECT banner_id FROM banners WHERE tenant = $5)
+		) AS v
+		WHERE 
+			v.rn > 1 AND (
+				($2::int > 0 AND v.rn > $2::int) OR 
+				($3::timestamptz IS NOT NULL AND v.updated_at < $3::timestamptz)
+			)
+	)`
+
+	tag, err := r.conn(ctx).Exec(ctx, query, bannerID, keep, before, domain.VersionPublished, tenant.FromContext(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// SetBannerVersionStatus moves a revision from one workflow status to
+// another. Publishing a revision also applies its feature, tags, flag and
+// activation window to the banner, in the same transaction. It fails with
+// ErrBannerVersionConflict if the revision is no longer in the expected
+// status.
+func (r *bannerRepo) SetBannerVersionStatus(ctx context.Context, bannerID int64, versionID int64, from string, to string) error {
+	const op = "repository.postgres.SetBannerVersionStatus"
+
+	const query = `
+		UPDATE 
+			banner_version
+			SET
+				status = $1,
+				updated_at = NOW()
+			WHERE
+				banner_id = $2 AND id = $3 AND status = $4 AND 
+				banner_id IN (SELECT banner_id FROM banners WHERE tenant = $5)`
+
+	err := r.WithinTx(ctx, func(ctx context.Context) error {
+		tag, err := r.conn(ctx).Exec(ctx, query, to, bannerID, versionID, from, tenant.FromContext(ctx))
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			return ErrBannerVersionConflict
+		}
+
+		if to == domain.VersionPublished {
+			return r.applyVersion(ctx, bannerID, versionID)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrBannerVersionConflict) {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
+
+func (r *bannerRepo) bumpRevision(ctx context.Context, bannerID int64) error {
+	const query = "UPDATE banners SET revision = revision + 1 WHERE banner_id = $1 AND tenant = $2"
+
+	_, err := r.conn(ctx).Exec(ctx, query, bannerID, tenant.FromContext(ctx))
+	return err
+}
+
+// applyVersion copies the fields a revision carries besides its content to
+// the banner and moves the banner to the next revision.
+func (r *bannerRepo) applyVersion(ctx context.Context, bannerID int64, versionID int64) error {
+	const query = `
+		UPDATE 
+			banners b
+			SET 
+				feature = v.feature,
+				tags = v.tags,
+				is_active = v.is_active,
+				start_at = v.start_at,
+				end_at = v.end_at,
+				revision = b.revision + 1
+			FROM 
+				banner_version v
+			WHERE 
+				v.id = $1 AND v.banner_id = b.banner_id AND b.banner_id = $2 AND b.tenant = $3`
+
+	_, err := r.conn(ctx).Exec(ctx, query, versionID, bannerID, tenant.FromContext(ctx))
+	return err
+}
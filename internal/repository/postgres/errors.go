@@ -1,8 +1,16 @@
 package repository
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/panzerhomer/banner/internal/domain"
+)
 
 var (
-	ErrBannerNotFound = errors.New("banner not found")
-	ErrBannerExists   = errors.New("banner exists")
+	ErrBannerNotFound        = domain.ErrBannerNotFound
+	ErrBannerVersionNotFound = domain.ErrBannerVersionNotFound
+	ErrBannerVersionConflict = domain.ErrBannerVersionConflict
+	ErrBannerRevisionStale   = domain.ErrBannerRevisionStale
+	ErrAPIKeyNotFound        = domain.ErrAPIKeyNotFound
+	ErrBannerExists          = errors.New("banner exists")
 )
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+// ScheduleBannerTransitions replaces the pending transitions of a banner with
+// the ones implied by its activation window. Moments that already passed are
+// not scheduled, the window itself keeps such banners hidden or visible.
+func (r *bannerRepo) ScheduleBannerTransitions(ctx context.Context, banner domain.Banner) error {
+	const op = "repository.postgres.ScheduleBannerTransitions"
+
+	const deletePendingQuery = `
+	DELETE FROM banner_transitions 
+	WHERE banner_id = $1 AND executed_at IS NULL AND banner_id IN (SELECT banner_id FROM banners WHERE tenant = $2)`
+	const insertQuery = `
+	INSERT INTO banner_transitions(banner_id, action, run_at) 
+	SELECT banner_id, $2, $3 FROM banners WHERE banner_id = $1 AND tenant = $4`
+
+	tenantID := tenant.FromContext(ctx)
+
+	err := r.WithinTx(ctx, func(ctx context.Context) error {
+		db := r.conn(ctx)
+
+		if _, err := db.Exec(ctx, deletePendingQuery, banner.BannerID, tenantID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if banner.StartAt != nil && banner.StartAt.After(now) {
+			if _, err := db.Exec(ctx, insertQuery, banner.BannerID, domain.TransitionPublish, *banner.StartAt, tenantID); err != nil {
+				return err
+			}
+		}
+		if banner.EndAt != nil && banner.EndAt.After(now) {
+			if _, err := db.Exec(ctx, insertQuery, banner.BannerID, domain.TransitionExpire, *banner.EndAt, tenantID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ApplyDueTransitions executes up to limit transitions that are due at
+// now: a publish switches the banner on and an expire switches it off. Each
+// transition is recorded with the replica that executed it. It works for
+// all tenants at once. Rows are claimed with FOR UPDATE SKIP LOCKED, so
+// several replicas can run it concurrently without applying a transition
+// twice.
+func (r *bannerRepo) ApplyDueTransitions(ctx context.Context, now time.Time, limit int, executedBy string) ([]domain.BannerTransition, error) {
+	const op = "repository.postgres.ApplyDueTransitions"
+
+	const selectDueQuery = `
+	SELECT 
+		id, 
+		banner_id, 
+		action, 
+		run_at
+	FROM 
+		banner_transitions
+	WHERE 
+		executed_at IS NULL AND run_at <= $1
+	ORDER BY 
+		run_at, id
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED`
+
+	// The revision is bumped as for any other change of the banner, so a
+	// client holding the old ETag has to re-read it.
+	const updateBannerQuery = `
+	UPDATE
+		banners b
+		SET
+			is_active = $1,
+			revision = b.revision + 1
+		FROM
+			(SELECT banner_id, is_active FROM banners WHERE banner_id = $2 FOR UPDATE) old
+		WHERE
+			b.banner_id = old.banner_id
+	RETURNING b.tenant, b.feature, b.tags, old.is_active`
+	const markExecutedQuery = "UPDATE banner_transitions SET executed_at = NOW(), executed_by = $1 WHERE id = $2 RETURNING executed_at"
+
+	var transitions []domain.BannerTransition
+	err := r.WithinTx(ctx, func(ctx context.Context) error {
+		db := r.conn(ctx)
+
+		rows, err := db.Query(ctx, selectDueQuery, now, limit)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var t domain.BannerTransition
+			if err := rows.Scan(&t.ID, &t.BannerID, &t.Action, &t.RunAt); err != nil {
+				rows.Close()
+				return err
+			}
+			transitions = append(transitions, t)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i := range transitions {
+			t := &transitions[i]
+
+			var wasActive bool
+			active := t.Action == domain.TransitionPublish
+			err := db.QueryRow(ctx, updateBannerQuery, active, t.BannerID).Scan(&t.Tenant, &t.FeatureID, &t.TagIds, &wasActive)
+			switch {
+			case err == nil:
+				if err := insertAuditEntry(tenant.With(ctx, t.Tenant), db, transitionAudit(*t, wasActive, active, executedBy)); err != nil {
+					return err
+				}
+			case !errors.Is(err, pgx.ErrNoRows):
+				return err
+			}
+
+			if err := db.QueryRow(ctx, markExecutedQuery, executedBy, t.ID).Scan(&t.ExecutedAt); err != nil {
+				return err
+			}
+			t.ExecutedBy = executedBy
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return transitions, nil
+}
+
+// transitionAudit is the audit entry of a transition applied by a scheduler
+// replica.
+func transitionAudit(t domain.BannerTransition, wasActive bool, active bool, executedBy string) domain.AuditEntry {
+	return domain.AuditEntry{
+		Actor:     "scheduler:" + executedBy,
+		Action:    domain.AuditTransitionPrefix + t.Action,
+		BannerID:  &t.BannerID,
+		FeatureID: &t.FeatureID,
+		Before:    json.RawMessage(fmt.Sprintf(`{"is_active":%t}`, wasActive)),
+		After:     json.RawMessage(fmt.Sprintf(`{"is_active":%t}`, active)),
+	}
+}
+
+// NextTransitionAt returns the moment of the earliest pending transition, or
+// nil if nothing is scheduled.
+func (r *bannerRepo) NextTransitionAt(ctx context.Context) (*time.Time, error) {
+	const op = "repository.postgres.NextTransitionAt"
+
+	const query = "SELECT MIN(run_at) FROM banner_transitions WHERE executed_at IS NULL"
+
+	var next *time.Time
+	if err := r.conn(ctx).QueryRow(ctx, query).Scan(&next); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return next, nil
+}
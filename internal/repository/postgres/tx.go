@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// querier is the part of pgx shared by the pool and a transaction, so every
+// repository method runs the same statements inside or outside of one.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type txKey struct{}
+
+// WithinTx runs fn in a transaction carried by the context it is given.
+// Repository calls made with that context join the transaction, which is
+// committed if fn returns nil and rolled back otherwise. A nested call runs
+// in a savepoint of the outer transaction.
+func (r *bannerRepo) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, r.db, fn)
+}
+
+// conn returns the transaction of ctx, or the pool if there is none.
+func (r *bannerRepo) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+// withinTx is WithinTx for any repository. All repositories share the
+// pool, so a transaction begun by one is joined by the others.
+func withinTx(ctx context.Context, db *pgxpool.Pool, fn func(ctx context.Context) error) error {
+	const op = "repository.postgres.WithinTx"
+
+	var (
+		tx  pgx.Tx
+		err error
+	)
+	if outer, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		tx, err = outer.Begin(ctx)
+	} else {
+		tx, err = db.BeginTx(ctx, pgx.TxOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func conn(ctx context.Context, db *pgxpool.Pool) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db
+}
@@ -0,0 +1,109 @@
+// Package scheduler executes the scheduled lifecycle transitions of banners:
+// it activates them at start_at and deactivates them at end_at.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/config"
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+type Repository interface {
+	ApplyDueTransitions(ctx context.Context, now time.Time, limit int, executedBy string) ([]domain.BannerTransition, error)
+	NextTransitionAt(ctx context.Context) (*time.Time, error)
+}
+
+type Cache interface {
+	DeleteBanner(tenantID string, tagIDs []int64, featureID int64) error
+}
+
+type Scheduler struct {
+	repo      Repository
+	cache     Cache
+	interval  time.Duration
+	batchSize int
+	replica   string
+}
+
+func New(repo Repository, cache Cache, cfg *config.Config) *Scheduler {
+	hostname, _ := os.Hostname()
+
+	return &Scheduler{
+		repo:      repo,
+		cache:     cache,
+		interval:  cfg.Scheduler.Interval,
+		batchSize: cfg.Scheduler.BatchSize,
+		replica:   fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+	}
+}
+
+// Run polls for due transitions until ctx is cancelled. Between polls it
+// sleeps until the next scheduled transition, but never longer than the
+// configured interval, so transitions created by other replicas are picked up.
+func (s *Scheduler) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		s.tick(ctx)
+		timer.Reset(s.nextWait(ctx))
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context) {
+	for {
+		transitions, err := s.repo.ApplyDueTransitions(ctx, time.Now(), s.batchSize, s.replica)
+		if err != nil {
+			log.Println("scheduler: applying transitions failed: ", err)
+			return
+		}
+
+		for _, t := range transitions {
+			log.Printf("scheduler: banner %d %s (scheduled at %s)", t.BannerID, t.Action, t.RunAt.Format(time.RFC3339))
+
+			if t.TagIds == nil {
+				continue
+			}
+			if err := s.cache.DeleteBanner(t.Tenant, t.TagIds, t.FeatureID); err != nil {
+				log.Println("scheduler: cache invalidation failed: ", err)
+			}
+		}
+
+		if len(transitions) < s.batchSize {
+			return
+		}
+	}
+}
+
+func (s *Scheduler) nextWait(ctx context.Context) time.Duration {
+	next, err := s.repo.NextTransitionAt(ctx)
+	if err != nil {
+		log.Println("scheduler: reading next transition failed: ", err)
+		return s.interval
+	}
+
+	if next == nil {
+		return s.interval
+	}
+
+	wait := time.Until(*next)
+	if wait < 0 {
+		return 0
+	}
+	if wait > s.interval {
+		return s.interval
+	}
+
+	return wait
+}
@@ -0,0 +1,198 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+// fakeRepo keeps pending transitions in memory and applies the due ones the
+// way the postgres repository does: oldest first, at most limit per call.
+type fakeRepo struct {
+	mu      sync.Mutex
+	pending []domain.BannerTransition
+	applied []domain.BannerTransition
+	calls   int
+	err     error
+}
+
+func (r *fakeRepo) ApplyDueTransitions(ctx context.Context, now time.Time, limit int, executedBy string) ([]domain.BannerTransition, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls++
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	var due, rest []domain.BannerTransition
+	for _, t := range r.pending {
+		if !t.RunAt.After(now) && len(due) < limit {
+			executedAt := now
+			t.ExecutedAt, t.ExecutedBy = &executedAt, executedBy
+			due = append(due, t)
+			continue
+		}
+		rest = append(rest, t)
+	}
+	r.pending = rest
+	r.applied = append(r.applied, due...)
+
+	return due, nil
+}
+
+func (r *fakeRepo) NextTransitionAt(ctx context.Context) (*time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	var next *time.Time
+	for _, t := range r.pending {
+		if next == nil || t.RunAt.Before(*next) {
+			runAt := t.RunAt
+			next = &runAt
+		}
+	}
+
+	return next, nil
+}
+
+type deletion struct {
+	tenant    string
+	featureID int64
+}
+
+type fakeCache struct {
+	mu      sync.Mutex
+	deleted []deletion
+	done    chan struct{}
+}
+
+func (c *fakeCache) DeleteBanner(tenantID string, tagIDs []int64, featureID int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deleted = append(c.deleted, deletion{tenantID, featureID})
+	if c.done != nil {
+		close(c.done)
+		c.done = nil
+	}
+
+	return nil
+}
+
+func transition(id int64, action string, runAt time.Time) domain.BannerTransition {
+	return domain.BannerTransition{
+		ID:        id,
+		BannerID:  id,
+		Tenant:    "shop",
+		Action:    action,
+		RunAt:     runAt,
+		FeatureID: id * 10,
+		TagIds:    []int64{id},
+	}
+}
+
+func TestNextWait(t *testing.T) {
+	interval := time.Minute
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		pending []domain.BannerTransition
+		err     error
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"nothing scheduled", nil, nil, interval, interval},
+		{"sleeps until the next transition", []domain.BannerTransition{transition(1, domain.TransitionPublish, now.Add(10*time.Second))}, nil, 9 * time.Second, 10 * time.Second},
+		{"picks the earliest transition", []domain.BannerTransition{transition(1, domain.TransitionExpire, now.Add(40*time.Second)), transition(2, domain.TransitionPublish, now.Add(20*time.Second))}, nil, 19 * time.Second, 20 * time.Second},
+		{"never longer than the interval", []domain.BannerTransition{transition(1, domain.TransitionPublish, now.Add(time.Hour))}, nil, interval, interval},
+		{"overdue runs at once", []domain.BannerTransition{transition(1, domain.TransitionPublish, now.Add(-time.Second))}, nil, 0, 0},
+		{"falls back to the interval on errors", nil, errors.New("db down"), interval, interval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scheduler{repo: &fakeRepo{pending: tt.pending, err: tt.err}, interval: interval}
+
+			wait := s.nextWait(context.Background())
+			if wait < tt.min || wait > tt.max {
+				t.Errorf("nextWait() = %s, want between %s and %s", wait, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestTickInvalidatesAppliedBanners(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	deleted := transition(3, domain.TransitionExpire, past)
+	deleted.TagIds = nil
+
+	repo := &fakeRepo{pending: []domain.BannerTransition{
+		transition(1, domain.TransitionPublish, past),
+		transition(2, domain.TransitionExpire, past),
+		deleted,
+		transition(4, domain.TransitionPublish, time.Now().Add(time.Hour)),
+	}}
+	cache := &fakeCache{}
+	s := &Scheduler{repo: repo, cache: cache, interval: time.Minute, batchSize: 2, replica: "test"}
+
+	s.tick(context.Background())
+
+	if len(repo.applied) != 3 {
+		t.Fatalf("applied %d transitions, want 3", len(repo.applied))
+	}
+	// A full batch means there may be more, so the tick asks again.
+	if repo.calls != 2 {
+		t.Errorf("ApplyDueTransitions() called %d times, want 2", repo.calls)
+	}
+	for _, applied := range repo.applied {
+		if applied.ExecutedBy != "test" {
+			t.Errorf("transition %d executed by %q, want %q", applied.ID, applied.ExecutedBy, "test")
+		}
+	}
+
+	// The banner of transition 3 is gone, there is nothing to evict.
+	want := []deletion{{"shop", 10}, {"shop", 20}}
+	if len(cache.deleted) != len(want) {
+		t.Fatalf("deleted = %v, want %v", cache.deleted, want)
+	}
+	for i := range want {
+		if cache.deleted[i] != want[i] {
+			t.Errorf("deleted[%d] = %v, want %v", i, cache.deleted[i], want[i])
+		}
+	}
+}
+
+func TestRunWakesUpForTheNextTransition(t *testing.T) {
+	repo := &fakeRepo{pending: []domain.BannerTransition{
+		transition(1, domain.TransitionPublish, time.Now().Add(50*time.Millisecond)),
+	}}
+	done := make(chan struct{})
+	cache := &fakeCache{done: done}
+	// The interval alone would not wake the loop up during the test.
+	s := &Scheduler{repo: repo, cache: cache, interval: time.Hour, batchSize: 10, replica: "test"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transition was not applied")
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.applied) != 1 || repo.applied[0].RunAt.After(*repo.applied[0].ExecutedAt) {
+		t.Errorf("applied = %+v, want transition 1 executed after its run_at", repo.applied)
+	}
+}
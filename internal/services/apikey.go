@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+const (
+	// keyCacheTTL is how long a resolved key is trusted without asking the
+	// database. Revoking or rotating a key evicts it at once on every
+	// replica the eviction reaches; without the cache bus other replicas
+	// only notice within this time.
+	keyCacheTTL = 30 * time.Second
+	// lastUsedFlushInterval is how often the last uses of keys are written,
+	// and so how precisely they are tracked.
+	lastUsedFlushInterval = time.Minute
+)
+
+type APIKeyRepository interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	InsertAPIKey(ctx context.Context, key domain.APIKey, hash string) (domain.APIKey, error)
+	GetAPIKeys(ctx context.Context) ([]domain.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error)
+	RotateAPIKey(ctx context.Context, id int64, prefix string, hash string) (domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+	TouchAPIKeys(ctx context.Context, uses map[int64]time.Time) error
+}
+
+// KeyPolicy tells which roles exist and which of them the caller may hand
+// out, so keys get neither unknown roles nor more than their creator has.
+type KeyPolicy interface {
+	HasRole(role string) bool
+	CanDelegate(principal auth.Principal, role string, scopes []auth.Permission) bool
+}
+
+// KeyEvictions tells the other replicas that a key was revoked or rotated.
+type KeyEvictions interface {
+	PublishAPIKey(id int64) error
+}
+
+type apiKeyService struct {
+	repo      APIKeyRepository
+	roles     KeyPolicy
+	audit     Auditor
+	evictions KeyEvictions
+
+	mu sync.Mutex
+	// resolved caches keys by the hash of their secret.
+	resolved map[string]resolvedKey
+	// used holds the last uses of keys that are not written yet.
+	used map[int64]time.Time
+}
+
+type resolvedKey struct {
+	key   domain.APIKey
+	until time.Time
+}
+
+// NewAPIKeyService creates the service. evictions may be nil for a single
+// replica.
+func NewAPIKeyService(repo APIKeyRepository, roles KeyPolicy, audit Auditor, evictions KeyEvictions) *apiKeyService {
+	return &apiKeyService{
+		repo:      repo,
+		roles:     roles,
+		audit:     audit,
+		evictions: evictions,
+		resolved:  make(map[string]resolvedKey),
+		used:      make(map[int64]time.Time),
+	}
+}
+
+// CreateAPIKey issues a new key. Keys default to the user role, which only
+// reads user banners.
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, request domain.APIKeyRequest) (domain.IssuedAPIKey, error) {
+	if request.Name == "" {
+		return domain.IssuedAPIKey{}, fmt.Errorf("%w: name is required", domain.ErrAPIKeyIncorrectData)
+	}
+	if request.Role == "" {
+		request.Role = auth.RoleUser
+	}
+	if !s.roles.HasRole(request.Role) {
+		return domain.IssuedAPIKey{}, fmt.Errorf("%w: unknown role %q", domain.ErrAPIKeyIncorrectData, request.Role)
+	}
+	for _, scope := range request.Scopes {
+		if !auth.Permission(scope).Valid() {
+			return domain.IssuedAPIKey{}, fmt.Errorf("%w: unknown scope %q", domain.ErrAPIKeyIncorrectData, scope)
+		}
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return domain.IssuedAPIKey{}, fmt.Errorf("%w: expires_at is in the past", domain.ErrAPIKeyIncorrectData)
+	}
+	if err := s.authorizeDelegation(ctx, request.Role, request.Scopes); err != nil {
+		return domain.IssuedAPIKey{}, err
+	}
+
+	secret, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return domain.IssuedAPIKey{}, err
+	}
+
+	var key domain.APIKey
+	err = s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		key, err = s.repo.InsertAPIKey(ctx, domain.APIKey{
+			Name:      request.Name,
+			Prefix:    prefix,
+			Role:      request.Role,
+			Scopes:    request.Scopes,
+			ExpiresAt: request.ExpiresAt,
+		}, hash)
+		if err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, domain.AuditEntry{Action: domain.AuditAPIKeyCreate}, nil, key)
+	})
+	if err != nil {
+		return domain.IssuedAPIKey{}, err
+	}
+
+	return domain.IssuedAPIKey{APIKey: key, Key: secret}, nil
+}
+
+func (s *apiKeyService) GetAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	return s.repo.GetAPIKeys(ctx)
+}
+
+func (s *apiKeyService) RotateAPIKey(ctx context.Context, id int64) (domain.IssuedAPIKey, error) {
+	secret, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return domain.IssuedAPIKey{}, err
+	}
+
+	var key domain.APIKey
+	err = s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		key, err = s.repo.RotateAPIKey(ctx, id, prefix, hash)
+		if err != nil {
+			return err
+		}
+
+		// The new secret gives whatever the key has, so rotating it is
+		// like issuing it anew.
+		if err := s.authorizeDelegation(ctx, key.Role, key.Scopes); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, domain.AuditEntry{Action: domain.AuditAPIKeyRotate}, nil, key)
+	})
+	if err != nil {
+		return domain.IssuedAPIKey{}, err
+	}
+
+	s.evict(id)
+
+	return domain.IssuedAPIKey{APIKey: key, Key: secret}, nil
+}
+
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, id int64) error {
+	err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.RevokeAPIKey(ctx, id); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, domain.AuditEntry{Action: domain.AuditAPIKeyRevoke}, nil, map[string]int64{"id": id})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.evict(id)
+
+	return nil
+}
+
+// authorizeDelegation checks that the caller holds everything a key with
+// role and scopes would give, and returns domain.ErrAccessDenied otherwise.
+func (s *apiKeyService) authorizeDelegation(ctx context.Context, role string, scopes []string) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return domain.ErrAccessDenied
+	}
+
+	permissions := make([]auth.Permission, 0, len(scopes))
+	for _, scope := range scopes {
+		permissions = append(permissions, auth.Permission(scope))
+	}
+
+	if !s.roles.CanDelegate(principal, role, permissions) {
+		return domain.ErrAccessDenied
+	}
+	return nil
+}
+
+// ResolveAPIKey returns the principal of a usable key and records its use.
+// Keys are cached for keyCacheTTL and their use is written in batches by
+// Run, so resolving a known key does not touch the database.
+func (s *apiKeyService) ResolveAPIKey(ctx context.Context, secret string) (auth.Principal, error) {
+	key, err := s.lookupKey(ctx, auth.HashAPIKey(secret))
+	if err != nil {
+		if errors.Is(err, domain.ErrAPIKeyNotFound) {
+			return auth.Principal{}, domain.ErrAPIKeyInvalid
+		}
+		return auth.Principal{}, err
+	}
+
+	now := time.Now()
+	if !key.UsableAt(now) {
+		return auth.Principal{}, domain.ErrAPIKeyInvalid
+	}
+
+	s.mu.Lock()
+	s.used[key.ID] = now
+	s.mu.Unlock()
+
+	principal := auth.Principal{
+		Subject: fmt.Sprintf("key:%d", key.ID),
+		Role:    key.Role,
+		Tenant:  key.Tenant,
+	}
+	if key.ExpiresAt != nil {
+		principal.ExpiresAt = *key.ExpiresAt
+	}
+	for _, scope := range key.Scopes {
+		principal.Scopes = append(principal.Scopes, auth.Permission(scope))
+	}
+
+	return principal, nil
+}
+
+// lookupKey returns the key with the given hash, from the cache if it is
+// there.
+func (s *apiKeyService) lookupKey(ctx context.Context, hash string) (domain.APIKey, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	cached, ok := s.resolved[hash]
+	s.mu.Unlock()
+	if ok && now.Before(cached.until) {
+		return cached.key, nil
+	}
+
+	key, err := s.repo.GetAPIKeyByHash(ctx, hash)
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+
+	s.mu.Lock()
+	s.resolved[hash] = resolvedKey{key: key, until: now.Add(keyCacheTTL)}
+	s.mu.Unlock()
+
+	return key, nil
+}
+
+// evict evicts a key that was just revoked or rotated from the caches of
+// this and the other replicas.
+func (s *apiKeyService) evict(id int64) {
+	s.ForgetAPIKey(id)
+
+	if s.evictions == nil {
+		return
+	}
+	// The change is committed; replicas the event misses notice within
+	// keyCacheTTL.
+	if err := s.evictions.PublishAPIKey(id); err != nil {
+		log.Println("publishing api key eviction failed: ", err)
+	}
+}
+
+// ForgetAPIKey evicts a key from the cache. Its hash may have just changed,
+// so it is found by ID.
+func (s *apiKeyService) ForgetAPIKey(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, cached := range s.resolved {
+		if cached.key.ID == id {
+			delete(s.resolved, hash)
+		}
+	}
+}
+
+// PurgeAPIKeys evicts every key from the cache.
+func (s *apiKeyService) PurgeAPIKeys() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resolved = make(map[string]resolvedKey)
+}
+
+// Run writes the last uses of keys every lastUsedFlushInterval until ctx is
+// done, and once more before it returns.
+func (s *apiKeyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(lastUsedFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			s.FlushLastUsed(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			s.FlushLastUsed(ctx)
+		}
+	}
+}
+
+// FlushLastUsed writes the last uses of keys recorded since the previous
+// flush. Uses that fail to be written are kept for the next one, unless a
+// newer use replaced them.
+func (s *apiKeyService) FlushLastUsed(ctx context.Context) {
+	s.mu.Lock()
+	uses := s.used
+	s.used = make(map[int64]time.Time)
+
+	// Expired cache entries would otherwise stay until the key is used again.
+	now := time.Now()
+	for hash, cached := range s.resolved {
+		if !now.Before(cached.until) {
+			delete(s.resolved, hash)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(uses) == 0 {
+		return
+	}
+
+	// Tracking the last use must not fail anything.
+	if err := s.repo.TouchAPIKeys(ctx, uses); err != nil {
+		log.Println("recording api key use failed: ", err)
+
+		s.mu.Lock()
+		for id, t := range uses {
+			if _, ok := s.used[id]; !ok {
+				s.used[id] = t
+			}
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/config"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+func newKeyFixture(t *testing.T) (*fakeStore, *apiKeyService) {
+	t.Helper()
+
+	policy, err := auth.NewPolicy(testConfig())
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+
+	store := newFakeStore()
+	return store, NewAPIKeyService(store, policy, NewAuditService(store, policy), nil)
+}
+
+func TestResolveAPIKeyIsCached(t *testing.T) {
+	store, s := newKeyFixture(t)
+	ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+	issued, err := s.CreateAPIKey(ctx, domain.APIKeyRequest{Name: "app"})
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.ResolveAPIKey(context.Background(), issued.Key); err != nil {
+			t.Fatalf("ResolveAPIKey() error = %v", err)
+		}
+	}
+
+	if store.hashLookups != 1 {
+		t.Errorf("looked up %d times, want 1", store.hashLookups)
+	}
+
+	// Uses are only written by a flush.
+	keys, _ := store.GetAPIKeys(ctx)
+	if keys[0].LastUsedAt != nil {
+		t.Errorf("last use written before a flush: %v", keys[0].LastUsedAt)
+	}
+
+	s.FlushLastUsed(context.Background())
+
+	keys, _ = store.GetAPIKeys(ctx)
+	if keys[0].LastUsedAt == nil {
+		t.Error("last use not written by a flush")
+	}
+}
+
+func TestFlushLastUsedRetries(t *testing.T) {
+	store, s := newKeyFixture(t)
+	ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+	issued, err := s.CreateAPIKey(ctx, domain.APIKeyRequest{Name: "app"})
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+	if _, err := s.ResolveAPIKey(context.Background(), issued.Key); err != nil {
+		t.Fatalf("ResolveAPIKey() error = %v", err)
+	}
+
+	store.fail["TouchAPIKeys"] = errInjected
+	s.FlushLastUsed(context.Background())
+	delete(store.fail, "TouchAPIKeys")
+	s.FlushLastUsed(context.Background())
+
+	keys, _ := store.GetAPIKeys(ctx)
+	if keys[0].LastUsedAt == nil {
+		t.Error("last use lost after a failed flush")
+	}
+}
+
+func TestRevokeAndRotateEvictCachedKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(s *apiKeyService, ctx context.Context, id int64) error
+	}{
+		{"revoke", func(s *apiKeyService, ctx context.Context, id int64) error {
+			return s.RevokeAPIKey(ctx, id)
+		}},
+		{"rotate", func(s *apiKeyService, ctx context.Context, id int64) error {
+			_, err := s.RotateAPIKey(ctx, id)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, s := newKeyFixture(t)
+			ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+			issued, err := s.CreateAPIKey(ctx, domain.APIKeyRequest{Name: "app"})
+			if err != nil {
+				t.Fatalf("CreateAPIKey() error = %v", err)
+			}
+			if _, err := s.ResolveAPIKey(context.Background(), issued.Key); err != nil {
+				t.Fatalf("ResolveAPIKey() error = %v", err)
+			}
+
+			if err := tt.change(s, ctx, issued.ID); err != nil {
+				t.Fatalf("error = %v", err)
+			}
+
+			if _, err := s.ResolveAPIKey(context.Background(), issued.Key); !errors.Is(err, domain.ErrAPIKeyInvalid) {
+				t.Errorf("ResolveAPIKey() of the old key error = %v, want %v", err, domain.ErrAPIKeyInvalid)
+			}
+		})
+	}
+}
+
+// replicaBus delivers key evictions to the other replicas right away, like
+// the cache bus does.
+type replicaBus struct {
+	replicas []*apiKeyService
+	from     *apiKeyService
+}
+
+func (b *replicaBus) PublishAPIKey(id int64) error {
+	for _, r := range b.replicas {
+		if r != b.from {
+			r.ForgetAPIKey(id)
+		}
+	}
+	return nil
+}
+
+func TestRevokedKeysAreRejectedOnEveryReplica(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(s *apiKeyService, ctx context.Context, id int64) error
+	}{
+		{"revoke", func(s *apiKeyService, ctx context.Context, id int64) error {
+			return s.RevokeAPIKey(ctx, id)
+		}},
+		{"rotate", func(s *apiKeyService, ctx context.Context, id int64) error {
+			_, err := s.RotateAPIKey(ctx, id)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := newKeyFixture(t)
+			policy, _ := auth.NewPolicy(testConfig())
+			audit := NewAuditService(store, policy)
+
+			bus := &replicaBus{}
+			a := NewAPIKeyService(store, policy, audit, bus)
+			b := NewAPIKeyService(store, policy, audit, nil)
+			bus.replicas, bus.from = []*apiKeyService{a, b}, a
+
+			ctx := as("alice", auth.RoleAdmin, tenant.Default)
+			issued, err := a.CreateAPIKey(ctx, domain.APIKeyRequest{Name: "app"})
+			if err != nil {
+				t.Fatalf("CreateAPIKey() error = %v", err)
+			}
+			for _, replica := range bus.replicas {
+				if _, err := replica.ResolveAPIKey(context.Background(), issued.Key); err != nil {
+					t.Fatalf("ResolveAPIKey() error = %v", err)
+				}
+			}
+
+			if err := tt.change(a, ctx, issued.ID); err != nil {
+				t.Fatalf("error = %v", err)
+			}
+
+			// b cached the key well within keyCacheTTL.
+			if _, err := b.ResolveAPIKey(context.Background(), issued.Key); !errors.Is(err, domain.ErrAPIKeyInvalid) {
+				t.Errorf("ResolveAPIKey() on the other replica error = %v, want %v", err, domain.ErrAPIKeyInvalid)
+			}
+		})
+	}
+}
+
+func TestAPIKeysCannotExceedTheirCreator(t *testing.T) {
+	_, s := newKeyFixture(t)
+	admin := as("alice", auth.RoleAdmin, tenant.Default)
+
+	cfg := testConfig()
+	cfg.RBAC.Roles = map[string][]config.Grant{
+		"key-manager": {
+			{Permission: string(auth.PermManageKeys)},
+			{Permission: string(auth.PermReadUserBanner)},
+		},
+	}
+	policy, err := auth.NewPolicy(cfg)
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+	s.roles = policy
+	manager := as("bob", "key-manager", tenant.Default)
+
+	tests := []struct {
+		name    string
+		request domain.APIKeyRequest
+		wantErr error
+	}{
+		{"own permissions", domain.APIKeyRequest{Name: "app", Role: auth.RoleUser}, nil},
+		{"higher role", domain.APIKeyRequest{Name: "app", Role: auth.RoleAdmin}, domain.ErrAccessDenied},
+		{"higher role scoped down", domain.APIKeyRequest{Name: "app", Role: auth.RoleAdmin, Scopes: []string{string(auth.PermReadUserBanner)}}, nil},
+		{"scope the caller lacks", domain.APIKeyRequest{Name: "app", Role: auth.RoleAdmin, Scopes: []string{string(auth.PermReadAudit)}}, domain.ErrAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.CreateAPIKey(manager, tt.request); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateAPIKey() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Rotating a key reveals its new secret, so it is checked the same way.
+	issued, err := s.CreateAPIKey(admin, domain.APIKeyRequest{Name: "ops", Role: auth.RoleAdmin})
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+	if _, err := s.RotateAPIKey(manager, issued.ID); !errors.Is(err, domain.ErrAccessDenied) {
+		t.Fatalf("RotateAPIKey() error = %v, want %v", err, domain.ErrAccessDenied)
+	}
+	if _, err := s.ResolveAPIKey(context.Background(), issued.Key); err != nil {
+		t.Errorf("denied rotation still changed the key: %v", err)
+	}
+}
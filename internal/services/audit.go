@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// systemActor is recorded for changes made without an authenticated caller.
+const systemActor = "system"
+
+type AuditRepository interface {
+	InsertAuditEntry(ctx context.Context, entry domain.AuditEntry) error
+	GetAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error)
+}
+
+// Auditor appends entries to the audit log. Services record inside the
+// transaction of the change, so an entry exists if and only if the change
+// was committed.
+type Auditor interface {
+	Record(ctx context.Context, entry domain.AuditEntry, before any, after any) error
+}
+
+// AuditAuthorizer tells for which features the caller may read the audit
+// log, as audit:read may be limited to some of them.
+type AuditAuthorizer interface {
+	Authorize(ctx context.Context, permission auth.Permission, featureID int64) error
+	AuthorizedFeatures(ctx context.Context, permission auth.Permission) ([]domain.FeatureRange, bool)
+}
+
+type auditService struct {
+	repo  AuditRepository
+	authz AuditAuthorizer
+}
+
+func NewAuditService(repo AuditRepository, authz AuditAuthorizer) *auditService {
+	return &auditService{repo: repo, authz: authz}
+}
+
+// Record fills in the actor and request of ctx and the snapshots, and
+// writes the entry.
+func (s *auditService) Record(ctx context.Context, entry domain.AuditEntry, before any, after any) error {
+	entry.Actor = systemActor
+	if principal, ok := auth.FromContext(ctx); ok {
+		entry.Actor = principal.Subject
+	}
+	entry.RequestID = middleware.GetReqID(ctx)
+
+	var err error
+	if entry.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if entry.After, err = snapshot(after); err != nil {
+		return err
+	}
+
+	return s.repo.InsertAuditEntry(ctx, entry)
+}
+
+// GetAuditEntries reads a page of the audit log, leaving out the features
+// the caller may not read it for.
+func (s *auditService) GetAuditEntries(ctx context.Context, filter domain.AuditFilter) (domain.AuditPage, error) {
+	if filter.FeatureID != 0 {
+		if err := s.authz.Authorize(ctx, auth.PermReadAudit, filter.FeatureID); err != nil {
+			return domain.AuditPage{}, err
+		}
+	} else {
+		ranges, all := s.authz.AuthorizedFeatures(ctx, auth.PermReadAudit)
+		if !all && len(ranges) == 0 {
+			return domain.AuditPage{}, domain.ErrAccessDenied
+		}
+		if !all {
+			filter.Features = ranges
+		}
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+
+	// One extra entry tells whether there is another page.
+	limit := filter.Limit
+	filter.Limit++
+
+	entries, err := s.repo.GetAuditEntries(ctx, filter)
+	if err != nil {
+		return domain.AuditPage{}, err
+	}
+
+	page := domain.AuditPage{Entries: entries}
+	if int64(len(entries)) > limit {
+		page.Entries = entries[:limit]
+		page.NextCursor = strconv.FormatInt(page.Entries[limit-1].ID, 10)
+	}
+	if page.Entries == nil {
+		page.Entries = []domain.AuditEntry{}
+	}
+
+	return page, nil
+}
+
+func snapshot(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// bannerAudit starts an audit entry about a banner.
+func bannerAudit(action string, banner domain.Banner) domain.AuditEntry {
+	bannerID, featureID := banner.BannerID, banner.FeatureID
+	return domain.AuditEntry{Action: action, BannerID: &bannerID, FeatureID: &featureID}
+}
+
+// versionStatus is the audit snapshot of a revision status change.
+type versionStatus struct {
+	Version int64  `json:"version"`
+	Status  string `json:"status"`
+}
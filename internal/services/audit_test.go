@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+func TestGetAuditEntriesLimitedToFeatures(t *testing.T) {
+	fx := newFixture(t)
+	admin := as("alice", auth.RoleAdmin, tenant.Default)
+
+	fx.createBanner(t, admin, 3, []int64{1}, "a")
+	fx.createBanner(t, admin, 7, []int64{1}, "b")
+	keys := NewAPIKeyService(fx.store, fx.policy, NewAuditService(fx.store, fx.policy), nil)
+	if _, err := keys.CreateAPIKey(admin, domain.APIKeyRequest{Name: "app"}); err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+
+	limited := as("bob", "limited", tenant.Default)
+	limitedAudit := NewAuditService(fx.store, limitedPolicy(t, "1-5", auth.PermReadAudit))
+	adminAudit := NewAuditService(fx.store, fx.policy)
+
+	tests := []struct {
+		name         string
+		service      *auditService
+		ctx          context.Context
+		filter       domain.AuditFilter
+		wantFeatures []int64
+		wantErr      error
+	}{
+		{"admin reads everything", adminAudit, admin, domain.AuditFilter{}, []int64{0, 7, 3}, nil},
+		{"limited reader gets its features", limitedAudit, limited, domain.AuditFilter{}, []int64{3}, nil},
+		{"limited reader asks for its feature", limitedAudit, limited, domain.AuditFilter{FeatureID: 3}, []int64{3}, nil},
+		{"limited reader asks for another feature", limitedAudit, limited, domain.AuditFilter{FeatureID: 7}, nil, domain.ErrAccessDenied},
+		{"no audit permission", adminAudit, as("carol", auth.RoleEditor, tenant.Default), domain.AuditFilter{}, nil, domain.ErrAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := tt.service.GetAuditEntries(tt.ctx, tt.filter)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetAuditEntries() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var features []int64
+			for _, e := range page.Entries {
+				var featureID int64
+				if e.FeatureID != nil {
+					featureID = *e.FeatureID
+				}
+				features = append(features, featureID)
+			}
+			if !reflect.DeepEqual(features, tt.wantFeatures) {
+				t.Errorf("features of the entries = %v, want %v", features, tt.wantFeatures)
+			}
+		})
+	}
+}
@@ -2,39 +2,118 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sync/atomic"
+	"time"
 
+	"github.com/panzerhomer/banner/internal/auth"
 	"github.com/panzerhomer/banner/internal/cache"
+	"github.com/panzerhomer/banner/internal/config"
 	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/jsonpatch"
+	"github.com/panzerhomer/banner/internal/tenant"
+	"golang.org/x/sync/singleflight"
 )
 
 type BannerRepository interface {
-	InsertBanner(banner domain.Banner) (int64, error)
-	GetBanners(tagIDs []int64, featureID int64, limit int64, offset int64) ([]domain.Banner, error)
-	GetBanner(tagIDs []int64, featureID int64, IsAdmin bool) ([]domain.Banner, error)
-	UpdateBannerById(banner domain.Banner) error
-	DeleteBannerById(bannerID int64) error
+	// WithinTx runs fn in a transaction. Repository calls made with the
+	// context passed to fn are part of it.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+
+	InsertBanner(ctx context.Context, banner domain.Banner) (int64, error)
+	GetBanners(ctx context.Context, tagIDs []int64, featureID int64, limit int64, offset int64) ([]domain.Banner, error)
+	GetBanner(ctx context.Context, tagIDs []int64, featureID int64, IsAdmin bool) ([]domain.Banner, error)
+	UpdateBannerById(ctx context.Context, banner domain.Banner) error
+	DeleteBannerById(ctx context.Context, bannerID int64, revision int64) error
+	GetBannerByID(ctx context.Context, bannerID int64) (domain.Banner, error)
+	GetBannerVersions(ctx context.Context, bannerID int64) ([]domain.BannerVersion, error)
+	GetBannerVersion(ctx context.Context, bannerID int64, versionID int64) (domain.BannerVersion, error)
+	InsertBannerVersion(ctx context.Context, bannerID int64, content any) (int64, error)
+	PruneBannerVersions(ctx context.Context, bannerID int64, keep int, before *time.Time) (int64, error)
+	SetBannerVersionStatus(ctx context.Context, bannerID int64, versionID int64, from string, to string) error
+	ScheduleBannerTransitions(ctx context.Context, banner domain.Banner) error
+	GetLiveBanners(ctx context.Context) ([]domain.Banner, error)
+}
+
+// Authorizer checks the permissions of the principal in ctx. Permissions
+// may be limited to some features, so checks name the feature involved.
+type Authorizer interface {
+	Authorize(ctx context.Context, permission auth.Permission, featureID int64) error
 }
 
 type bannerService struct {
-	repo  BannerRepository
-	redis *cache.Redis
+	repo        BannerRepository
+	authz       Authorizer
+	audit       Auditor
+	cache       cache.BannerCache
+	retention   retentionPolicies
+	freshTTL    time.Duration
+	staleTTL    time.Duration
+	negativeTTL time.Duration
+	// lookupTimeout bounds the shared database lookups of user banners.
+	lookupTimeout time.Duration
+	warmUp        struct {
+		concurrency int
+		budget      time.Duration
+	}
+
+	// lookups collapses concurrent database lookups of the same banner.
+	lookups   singleflight.Group
+	misses    atomic.Int64
+	queries   atomic.Int64
+	coalesced atomic.Int64
 }
 
-func NewBannerService(repo BannerRepository, redis *cache.Redis) *bannerService {
-	return &bannerService{repo: repo, redis: redis}
+func NewBannerService(repo BannerRepository, bannerCache cache.BannerCache, authz Authorizer, audit Auditor, cfg *config.Config) *bannerService {
+	s := &bannerService{
+		repo:          repo,
+		authz:         authz,
+		audit:         audit,
+		cache:         bannerCache,
+		retention:     newRetentionPolicies(cfg),
+		freshTTL:      cfg.Cache.TTL,
+		staleTTL:      cfg.Cache.StaleTTL,
+		negativeTTL:   cfg.Cache.NegativeTTL,
+		lookupTimeout: cfg.Cache.LookupTimeout,
+	}
+	s.warmUp.concurrency = cfg.Cache.WarmUp.Concurrency
+	s.warmUp.budget = cfg.Cache.WarmUp.Budget
+
+	return s
 }
 
 func (s *bannerService) CreateBanner(ctx context.Context, banner domain.Banner) (int64, error) {
-	bannerID, err := s.repo.InsertBanner(banner)
+	banner.TagIds = domain.NormalizeTags(banner.TagIds)
+
+	if err := s.authz.Authorize(ctx, auth.PermWriteBanners, banner.FeatureID); err != nil {
+		return -1, err
+	}
+
+	err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		bannerID, err := s.repo.InsertBanner(ctx, banner)
+		if err != nil {
+			return err
+		}
+
+		banner.BannerID = bannerID
+		if err := s.repo.ScheduleBannerTransitions(ctx, banner); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, bannerAudit(domain.AuditBannerCreate, banner), nil, banner)
+	})
 	if err != nil {
 		return -1, err
 	}
 
-	banner.BannerID = bannerID
-	s.redis.SaveBanner(bannerID, banner)
+	// The cache is only touched once the transaction is committed, so it
+	// never sees a banner that was rolled back.
+	s.invalidate(ctx, banner)
+	s.pruneVersions(ctx, banner)
 
-	return bannerID, nil
+	return banner.BannerID, nil
 }
 
 func (s *bannerService) GetBanners(ctx context.Context, banner domain.BannerFilter) ([]domain.Banner, error) {
@@ -43,7 +122,11 @@ func (s *bannerService) GetBanners(ctx context.Context, banner domain.BannerFilt
 		banner.Offset = 0
 	}
 
-	banners, err := s.repo.GetBanners(banner.TagIds, banner.FeatureID, banner.Limit, banner.Offset)
+	if err := s.authz.Authorize(ctx, auth.PermReadBanners, banner.FeatureID); err != nil {
+		return nil, err
+	}
+
+	banners, err := s.repo.GetBanners(ctx, banner.TagIds, banner.FeatureID, banner.Limit, banner.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -51,41 +134,532 @@ func (s *bannerService) GetBanners(ctx context.Context, banner domain.BannerFilt
 	return banners, nil
 }
 
-func (s *bannerService) GetBanner(ctx context.Context, tagIDs []int64, featureID int64, lastVersion bool, isAdmin bool) ([]domain.Banner, error) {
+func (s *bannerService) GetBanner(ctx context.Context, tagIDs []int64, featureID int64, lastVersion bool) (domain.BannerLookup, error) {
+	tagIDs = domain.NormalizeTags(tagIDs)
+
+	if err := s.authz.Authorize(ctx, auth.PermReadUserBanner, featureID); err != nil {
+		return domain.BannerLookup{}, err
+	}
+
+	// Callers that may read inactive banners see the same ones as in the
+	// admin listing.
+	isAdmin := s.authz.Authorize(ctx, auth.PermReadInactive, featureID) == nil
+
+	tenantID := tenant.FromContext(ctx)
+
+	var entry *cache.Entry
+
 	if !lastVersion {
-		banner, err := s.redis.GetBanner(tagIDs, featureID)
-		if err != nil {
-			// return nil, err
-			log.Println("no cache: ", err)
+		entry = s.cachedEntry(tenantID, tagIDs, featureID, isAdmin)
+		if entry != nil {
+			if entry.NotFound {
+				return domain.BannerLookup{}, domain.ErrBannerNotFound
+			}
+
+			// Serve a stale copy right away, marked as such, and refresh it in
+			// the background. While the database is down every refresh fails
+			// and the copy keeps being served stale until it expires.
+			stale := !entry.Fresh(time.Now())
+			if stale {
+				go func() {
+					if _, err := s.lookup(detach(ctx), tagIDs, featureID, isAdmin); err != nil {
+						log.Println("background refresh failed: ", err)
+					}
+				}()
+			}
+
+			log.Println("got banner from cache")
+			return entryLookup(entry, stale), nil
 		}
-		if banner != nil {
-			log.Println("got banner from redis cache")
-			return []domain.Banner{*banner}, nil
+	}
+
+	banners, err := s.lookup(ctx, tagIDs, featureID, isAdmin)
+	if err != nil {
+		if lastVersion {
+			entry = s.cachedEntry(tenantID, tagIDs, featureID, isAdmin)
+		}
+		if entry != nil {
+			log.Println("database lookup failed, serving cached copy: ", err)
+			if entry.NotFound {
+				return domain.BannerLookup{}, domain.ErrBannerNotFound
+			}
+			return entryLookup(entry, true), nil
+		}
+		return domain.BannerLookup{}, err
+	}
+
+	if len(banners) == 0 {
+		return domain.BannerLookup{}, domain.ErrBannerNotFound
+	}
+
+	result := domain.BannerLookup{Banners: banners, ETag: cache.ETag(banners...)}
+
+	// Clients asking for the last revision want to skip caches, so they get
+	// nothing to reuse.
+	if !lastVersion {
+		now := time.Now()
+		result.MaxAge = s.freshTTL
+		for _, b := range banners {
+			if b.EndAt != nil && b.EndAt.Sub(now) < result.MaxAge {
+				result.MaxAge = b.EndAt.Sub(now)
+			}
 		}
 	}
 
-	banner, err := s.repo.GetBanner(tagIDs, featureID, isAdmin)
+	return result, nil
+}
+
+// entryLookup serves a lookup from a cache entry. Clients may reuse it for
+// as long as the entry stays fresh.
+func entryLookup(entry *cache.Entry, stale bool) domain.BannerLookup {
+	result := domain.BannerLookup{
+		Banners: []domain.Banner{entry.Banner},
+		Stale:   stale,
+		ETag:    entry.ETag,
+	}
+
+	// Entries written before ETags were cached do not carry one.
+	if result.ETag == "" {
+		result.ETag = cache.ETag(entry.Banner)
+	}
+
+	if maxAge := time.Until(entry.FreshUntil); maxAge > 0 && !stale {
+		result.MaxAge = maxAge
+	}
+
+	return result
+}
+
+// cachedEntry returns the cache entry for the banner, if any. Negative
+// entries only hold for users: admins also see inactive banners.
+func (s *bannerService) cachedEntry(tenantID string, tagIDs []int64, featureID int64, isAdmin bool) *cache.Entry {
+	entry, err := s.cache.GetBanner(tenantID, tagIDs, featureID)
+	if err != nil && !errors.Is(err, cache.ErrCacheMiss) {
+		log.Println("cache lookup failed: ", err)
+	}
+
+	if entry != nil && entry.NotFound && isAdmin {
+		return nil
+	}
+
+	return entry
+}
+
+// lookup reads a user banner of the tenant of ctx from the database and
+// caches it. Concurrent lookups of the same banner share a single query,
+// which therefore does not stop when the caller that started it goes away;
+// it is bounded by lookupTimeout instead.
+func (s *bannerService) lookup(ctx context.Context, tagIDs []int64, featureID int64, isAdmin bool) ([]domain.Banner, error) {
+	s.misses.Add(1)
+
+	var executed bool
+	tenantID := tenant.FromContext(ctx)
+	key := fmt.Sprint(tenantID, featureID, tagIDs, isAdmin)
+
+	result, err, _ := s.lookups.Do(key, func() (any, error) {
+		executed = true
+		s.queries.Add(1)
+
+		ctx, cancel := context.WithTimeout(detach(ctx), s.lookupTimeout)
+		defer cancel()
+
+		banner, err := s.repo.GetBanner(ctx, tagIDs, featureID, isAdmin)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Println("got banner from database")
+
+		now := time.Now()
+		if len(banner) == 0 {
+			entry := cache.NewNotFoundEntry(tenantID, tagIDs, featureID, now, s.negativeTTL)
+			if err := s.cache.SaveBanner(entry); err != nil {
+				log.Println("cache save failed: ", err)
+			}
+		}
+
+		for _, b := range banner {
+			entry, ok := cache.NewEntry(b, now, s.freshTTL, s.staleTTL)
+			if !ok {
+				continue
+			}
+			if err := s.cache.SaveBanner(entry); err != nil {
+				log.Println("cache save failed: ", err)
+			}
+		}
+
+		return banner, nil
+	})
+	if !executed {
+		s.coalesced.Add(1)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	log.Println("got banner from database")
-
-	return banner, nil
+	return result.([]domain.Banner), nil
 }
 
 func (s *bannerService) UpdateBanner(ctx context.Context, banner domain.Banner) error {
-	if err := s.repo.UpdateBannerById(banner); err != nil {
+	banner.TagIds = domain.NormalizeTags(banner.TagIds)
+
+	var old domain.Banner
+	err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if old, err = s.repo.GetBannerByID(ctx, banner.BannerID); err != nil {
+			return err
+		}
+
+		// Moving a banner to another feature needs access to both.
+		if err := s.authz.Authorize(ctx, auth.PermWriteBanners, old.FeatureID); err != nil {
+			return err
+		}
+		if err := s.authz.Authorize(ctx, auth.PermWriteBanners, banner.FeatureID); err != nil {
+			return err
+		}
+
+		// The edit waits in the pending revision until it is approved, only
+		// the revision of the banner moves on.
+		if err := s.repo.UpdateBannerById(ctx, banner); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, bannerAudit(domain.AuditBannerUpdate, banner), old, banner)
+	})
+	if err != nil {
 		return err
 	}
 
+	// Cached copies carry the old revision.
+	s.invalidate(ctx, old)
+
 	return nil
 }
 
-func (s *bannerService) DeleteBanner(ctx context.Context, bannerID int64) error {
-	if err := s.repo.DeleteBannerById(bannerID); err != nil {
+func (s *bannerService) DeleteBanner(ctx context.Context, bannerID int64, revision int64) error {
+	var old domain.Banner
+	err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if old, err = s.authorizeBanner(ctx, auth.PermWriteBanners, bannerID); err != nil {
+			return err
+		}
+
+		if err := s.repo.DeleteBannerById(ctx, bannerID, revision); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, bannerAudit(domain.AuditBannerDelete, old), old, nil)
+	})
+	if err != nil {
 		return err
 	}
 
+	s.invalidate(ctx, old)
+
 	return nil
 }
+
+func (s *bannerService) GetBannerVersions(ctx context.Context, bannerID int64) ([]domain.BannerVersion, error) {
+	if _, err := s.authorizeBanner(ctx, auth.PermReadBanners, bannerID); err != nil {
+		return nil, err
+	}
+
+	versions, err := s.repo.GetBannerVersions(ctx, bannerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+func (s *bannerService) ActivateBannerVersion(ctx context.Context, bannerID int64, versionID int64) (int64, error) {
+	var (
+		banner       domain.Banner
+		newVersionID int64
+	)
+	err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if banner, err = s.authorizeBanner(ctx, auth.PermPublishBanners, bannerID); err != nil {
+			return err
+		}
+
+		version, err := s.repo.GetBannerVersion(ctx, bannerID, versionID)
+		if err != nil {
+			return err
+		}
+
+		// Rolling back must not bypass review, so only content that has
+		// already been published can be reactivated.
+		if version.Status != domain.VersionPublished {
+			return domain.ErrBannerVersionConflict
+		}
+
+		newVersionID, err = s.repo.InsertBannerVersion(ctx, bannerID, version.Content)
+		if err != nil {
+			return err
+		}
+
+		after := banner
+		after.Content = version.Content
+		return s.audit.Record(ctx, bannerAudit(domain.AuditVersionActivate, banner), banner, after)
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	s.invalidate(ctx, banner)
+
+	s.pruneVersions(ctx, banner)
+
+	return newVersionID, nil
+}
+
+func (s *bannerService) PruneBannerVersions(ctx context.Context, bannerID int64) (int64, error) {
+	var pruned int64
+	err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		banner, err := s.authorizeBanner(ctx, auth.PermWriteBanners, bannerID)
+		if err != nil {
+			return err
+		}
+
+		if pruned, err = s.prune(ctx, banner); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, bannerAudit(domain.AuditVersionsPrune, banner), nil, map[string]int64{"pruned": pruned})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return pruned, nil
+}
+
+func (s *bannerService) prune(ctx context.Context, banner domain.Banner) (int64, error) {
+	bannerID := banner.BannerID
+
+	policy := s.retention.For(banner)
+	if policy.Forever() {
+		return 0, nil
+	}
+
+	pruned, err := s.repo.PruneBannerVersions(ctx, bannerID, policy.Keep, policy.Cutoff(time.Now()))
+	if err != nil {
+		return 0, err
+	}
+
+	if pruned > 0 {
+		log.Printf("pruned %d revisions of banner %d (keep=%d, days=%d)", pruned, bannerID, policy.Keep, policy.Days)
+	}
+
+	return pruned, nil
+}
+
+// SubmitBannerVersion sends a draft revision to review.
+func (s *bannerService) SubmitBannerVersion(ctx context.Context, bannerID int64, versionID int64) error {
+	return s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		banner, err := s.authorizeBanner(ctx, auth.PermWriteBanners, bannerID)
+		if err != nil {
+			return err
+		}
+
+		return s.transitionVersion(ctx, banner, versionID, domain.VersionDraft, domain.VersionInReview, domain.AuditVersionSubmit)
+	})
+}
+
+// ApproveBannerVersion publishes a revision under review, making it the one
+// served to users.
+func (s *bannerService) ApproveBannerVersion(ctx context.Context, bannerID int64, versionID int64) error {
+	var banner, approved domain.Banner
+	err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if banner, err = s.authorizeBanner(ctx, auth.PermPublishBanners, bannerID); err != nil {
+			return err
+		}
+
+		version, err := s.repo.GetBannerVersion(ctx, bannerID, versionID)
+		if err != nil {
+			return err
+		}
+
+		// Approving moves the banner to the feature of the revision, which
+		// needs access to both.
+		if err := s.authz.Authorize(ctx, auth.PermPublishBanners, version.FeatureID); err != nil {
+			return err
+		}
+
+		if err := s.transitionVersion(ctx, banner, versionID, domain.VersionInReview, domain.VersionPublished, domain.AuditVersionApprove); err != nil {
+			return err
+		}
+
+		approved = banner
+		approved.FeatureID = version.FeatureID
+		approved.TagIds = version.TagIds
+		approved.IsActive = version.IsActive
+		approved.StartAt = version.StartAt
+		approved.EndAt = version.EndAt
+		approved.Content = version.Content
+
+		return s.repo.ScheduleBannerTransitions(ctx, approved)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Feature and tags form the cache key, so both the old and the new key
+	// may hold this banner.
+	s.invalidate(ctx, banner, approved)
+
+	s.pruneVersions(ctx, approved)
+
+	return nil
+}
+
+// RejectBannerVersion sends a revision under review back to draft.
+func (s *bannerService) RejectBannerVersion(ctx context.Context, bannerID int64, versionID int64) error {
+	return s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		banner, err := s.authorizeBanner(ctx, auth.PermPublishBanners, bannerID)
+		if err != nil {
+			return err
+		}
+
+		return s.transitionVersion(ctx, banner, versionID, domain.VersionInReview, domain.VersionDraft, domain.AuditVersionReject)
+	})
+}
+
+// transitionVersion moves a revision between workflow statuses and records
+// the change as action. It must run in a transaction.
+func (s *bannerService) transitionVersion(ctx context.Context, banner domain.Banner, versionID int64, from string, to string, action string) error {
+	version, err := s.repo.GetBannerVersion(ctx, banner.BannerID, versionID)
+	if err != nil {
+		return err
+	}
+
+	if version.Status != from {
+		return domain.ErrBannerVersionConflict
+	}
+
+	if err := s.repo.SetBannerVersionStatus(ctx, banner.BannerID, versionID, from, to); err != nil {
+		return err
+	}
+
+	return s.audit.Record(ctx, bannerAudit(action, banner), versionStatus{versionID, from}, versionStatus{versionID, to})
+}
+
+// DiffBannerVersions compares two revisions of a banner. A zero toID compares
+// against the active revision.
+func (s *bannerService) DiffBannerVersions(ctx context.Context, bannerID int64, fromID int64, toID int64) (domain.BannerDiff, error) {
+	if _, err := s.authorizeBanner(ctx, auth.PermReadBanners, bannerID); err != nil {
+		return domain.BannerDiff{}, err
+	}
+
+	from, err := s.repo.GetBannerVersion(ctx, bannerID, fromID)
+	if err != nil {
+		return domain.BannerDiff{}, err
+	}
+
+	var to domain.BannerVersion
+	if toID == 0 {
+		versions, err := s.repo.GetBannerVersions(ctx, bannerID)
+		if err != nil {
+			return domain.BannerDiff{}, err
+		}
+		active := -1
+		for i, v := range versions {
+			if v.Active {
+				active = i
+				break
+			}
+		}
+		if active < 0 {
+			return domain.BannerDiff{}, domain.ErrBannerVersionNotFound
+		}
+		to = versions[active]
+	} else {
+		to, err = s.repo.GetBannerVersion(ctx, bannerID, toID)
+		if err != nil {
+			return domain.BannerDiff{}, err
+		}
+	}
+
+	patch, err := jsonpatch.Diff(revisionDocument(from), revisionDocument(to))
+	if err != nil {
+		return domain.BannerDiff{}, err
+	}
+
+	return domain.BannerDiff{
+		BannerID: bannerID,
+		From:     from.VersionID,
+		To:       to.VersionID,
+		Patch:    patch,
+		Summary:  jsonpatch.Summary(patch),
+	}, nil
+}
+
+// revisionDocument is the JSON document two revisions are compared as.
+func revisionDocument(v domain.BannerVersion) any {
+	return struct {
+		FeatureID int64      `json:"feature_id"`
+		TagIds    []int64    `json:"tag_ids"`
+		IsActive  bool       `json:"is_active"`
+		StartAt   *time.Time `json:"start_at"`
+		EndAt     *time.Time `json:"end_at"`
+		Content   any        `json:"content"`
+	}{v.FeatureID, v.TagIds, v.IsActive, v.StartAt, v.EndAt, v.Content}
+}
+
+func (s *bannerService) CacheStats(ctx context.Context) domain.CacheReport {
+	return domain.CacheReport{
+		Tiers:     s.cache.Stats(),
+		Lookups:   s.misses.Load(),
+		Queries:   s.queries.Load(),
+		Coalesced: s.coalesced.Load(),
+	}
+}
+
+// authorizeBanner loads a banner and checks that the caller has permission
+// for its feature.
+func (s *bannerService) authorizeBanner(ctx context.Context, permission auth.Permission, bannerID int64) (domain.Banner, error) {
+	banner, err := s.repo.GetBannerByID(ctx, bannerID)
+	if err != nil {
+		return domain.Banner{}, err
+	}
+
+	if err := s.authz.Authorize(ctx, permission, banner.FeatureID); err != nil {
+		return domain.Banner{}, err
+	}
+
+	return banner, nil
+}
+
+// invalidate evicts the cache entries of the given banner states. Mutations
+// pass the state before and after the change, as feature and tags are part
+// of the cache key. The banners belong to the tenant of ctx.
+func (s *bannerService) invalidate(ctx context.Context, banners ...domain.Banner) {
+	tenantID := tenant.FromContext(ctx)
+	for _, b := range banners {
+		if err := s.cache.DeleteBanner(tenantID, b.TagIds, b.FeatureID); err != nil {
+			log.Println("cache invalidation failed: ", err)
+		}
+	}
+}
+
+// pruneVersions applies the retention policy after a revision was written.
+// A failed prune does not fail the write, it will be retried on the next one.
+func (s *bannerService) pruneVersions(ctx context.Context, banner domain.Banner) {
+	if _, err := s.prune(ctx, banner); err != nil {
+		log.Println("pruning banner versions failed: ", err)
+	}
+}
+
+// detachedContext keeps the values of its parent but is never cancelled, for
+// work that outlives the request that started it.
+type detachedContext struct{ context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+func TestUpdateBannerWaitsForApproval(t *testing.T) {
+	fx := newFixture(t)
+	ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+	banner := fx.createBanner(t, ctx, 1, []int64{1, 2}, map[string]any{"title": "a"})
+
+	edit := banner
+	edit.FeatureID = 2
+	edit.TagIds = []int64{3}
+	edit.IsActive = false
+	edit.Content = map[string]any{"title": "b"}
+	if err := fx.service.UpdateBanner(ctx, edit); err != nil {
+		t.Fatalf("UpdateBanner() error = %v", err)
+	}
+
+	pending, _ := fx.store.GetBannerByID(ctx, banner.BannerID)
+	if pending.FeatureID != 1 || !reflect.DeepEqual(pending.TagIds, []int64{1, 2}) || !pending.IsActive {
+		t.Fatalf("banner before approval = %+v, want the published feature, tags and flag", pending)
+	}
+	if pending.Revision != banner.Revision+1 {
+		t.Errorf("revision before approval = %d, want %d", pending.Revision, banner.Revision+1)
+	}
+
+	draft := pendingVersion(t, fx, ctx, banner.BannerID)
+	if err := fx.service.SubmitBannerVersion(ctx, banner.BannerID, draft); err != nil {
+		t.Fatalf("SubmitBannerVersion() error = %v", err)
+	}
+	if err := fx.service.ApproveBannerVersion(ctx, banner.BannerID, draft); err != nil {
+		t.Fatalf("ApproveBannerVersion() error = %v", err)
+	}
+
+	approved, _ := fx.store.GetBannerByID(ctx, banner.BannerID)
+	if approved.FeatureID != 2 || !reflect.DeepEqual(approved.TagIds, []int64{3}) || approved.IsActive {
+		t.Errorf("banner after approval = %+v, want feature 2, tags [3], inactive", approved)
+	}
+	if !reflect.DeepEqual(approved.Content, edit.Content) {
+		t.Errorf("content after approval = %v, want %v", approved.Content, edit.Content)
+	}
+}
+
+func TestApproveBannerVersionNeedsTargetFeature(t *testing.T) {
+	fx := newFixture(t)
+	admin := as("alice", auth.RoleAdmin, tenant.Default)
+
+	banner := fx.createBanner(t, admin, 1, []int64{1}, map[string]any{"title": "a"})
+
+	edit := banner
+	edit.FeatureID = 2
+	if err := fx.service.UpdateBanner(admin, edit); err != nil {
+		t.Fatalf("UpdateBanner() error = %v", err)
+	}
+	draft := pendingVersion(t, fx, admin, banner.BannerID)
+	if err := fx.service.SubmitBannerVersion(admin, banner.BannerID, draft); err != nil {
+		t.Fatalf("SubmitBannerVersion() error = %v", err)
+	}
+
+	fx.service.authz = limitedPolicy(t, "1", auth.PermReadBanners, auth.PermPublishBanners)
+
+	err := fx.service.ApproveBannerVersion(as("bob", "limited", tenant.Default), banner.BannerID, draft)
+	if !errors.Is(err, domain.ErrAccessDenied) {
+		t.Fatalf("ApproveBannerVersion() error = %v, want %v", err, domain.ErrAccessDenied)
+	}
+
+	if b, _ := fx.store.GetBannerByID(admin, banner.BannerID); b.FeatureID != 1 {
+		t.Errorf("feature after denied approval = %d, want 1", b.FeatureID)
+	}
+}
+
+func TestUpdateBannerEvictsOldAndNewKeys(t *testing.T) {
+	fx := newFixture(t)
+	ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+	banner := fx.createBanner(t, ctx, 1, []int64{1, 2}, map[string]any{"title": "a"})
+
+	fx.seed(t, tenant.Default, []int64{1, 2}, 1)
+	fx.seed(t, tenant.Default, []int64{3}, 2)
+
+	edit := banner
+	edit.FeatureID = 2
+	edit.TagIds = []int64{3}
+	if err := fx.service.UpdateBanner(ctx, edit); err != nil {
+		t.Fatalf("UpdateBanner() error = %v", err)
+	}
+
+	// The banner moved to the next revision, so cached copies are stale.
+	if fx.cached(tenant.Default, []int64{1, 2}, 1) {
+		t.Error("old key still cached after update")
+	}
+
+	fx.seed(t, tenant.Default, []int64{1, 2}, 1)
+
+	draft := pendingVersion(t, fx, ctx, banner.BannerID)
+	if err := fx.service.SubmitBannerVersion(ctx, banner.BannerID, draft); err != nil {
+		t.Fatalf("SubmitBannerVersion() error = %v", err)
+	}
+	if err := fx.service.ApproveBannerVersion(ctx, banner.BannerID, draft); err != nil {
+		t.Fatalf("ApproveBannerVersion() error = %v", err)
+	}
+
+	if fx.cached(tenant.Default, []int64{1, 2}, 1) {
+		t.Error("old key still cached after the move was approved")
+	}
+	if fx.cached(tenant.Default, []int64{3}, 2) {
+		t.Error("new key still cached after the move was approved")
+	}
+}
+
+func TestDeleteBannerEvicts(t *testing.T) {
+	fx := newFixture(t)
+	ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+	banner := fx.createBanner(t, ctx, 1, []int64{1}, map[string]any{"title": "a"})
+
+	if _, err := fx.service.GetBanner(ctx, []int64{1}, 1, false); err != nil {
+		t.Fatalf("GetBanner() error = %v", err)
+	}
+	if !fx.cached(tenant.Default, []int64{1}, 1) {
+		t.Fatal("banner not cached after lookup")
+	}
+
+	if err := fx.service.DeleteBanner(ctx, banner.BannerID, banner.Revision); err != nil {
+		t.Fatalf("DeleteBanner() error = %v", err)
+	}
+
+	if fx.cached(tenant.Default, []int64{1}, 1) {
+		t.Error("banner still cached after delete")
+	}
+}
+
+func TestUnknownBanner(t *testing.T) {
+	fx := newFixture(t)
+	ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+	fx.createBanner(t, ctx, 1, []int64{1}, map[string]any{"title": "a"})
+	fx.seed(t, tenant.Default, []int64{1}, 1)
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"update", func() error {
+			return fx.service.UpdateBanner(ctx, domain.Banner{BannerID: 999, FeatureID: 1, TagIds: []int64{1}, Revision: 1})
+		}},
+		{"delete", func() error { return fx.service.DeleteBanner(ctx, 999, 1) }},
+		{"versions", func() error {
+			_, err := fx.service.GetBannerVersions(ctx, 999)
+			return err
+		}},
+		{"approve", func() error { return fx.service.ApproveBannerVersion(ctx, 999, 1) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The handlers answer ErrBannerNotFound with 404.
+			if err := tt.call(); !errors.Is(err, domain.ErrBannerNotFound) {
+				t.Errorf("error = %v, want %v", err, domain.ErrBannerNotFound)
+			}
+		})
+	}
+
+	if !fx.cached(tenant.Default, []int64{1}, 1) {
+		t.Error("failed writes evicted an unrelated key")
+	}
+}
+
+func TestLookupTimeout(t *testing.T) {
+	fx := newFixture(t)
+	fx.store.hang = true
+	fx.service.lookupTimeout = 20 * time.Millisecond
+
+	// The caller gives up first: the shared lookup must still end.
+	ctx, cancel := context.WithCancel(as("alice", auth.RoleAdmin, tenant.Default))
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := fx.service.GetBanner(ctx, []int64{1}, 1, false)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("GetBanner() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("GetBanner() did not return after the lookup timeout")
+	}
+}
+
+// publish edits the content of a banner and takes the edit through review.
+func (fx *fixture) publish(t *testing.T, ctx context.Context, bannerID int64, content any) {
+	t.Helper()
+
+	banner, err := fx.store.GetBannerByID(ctx, bannerID)
+	if err != nil {
+		t.Fatalf("GetBannerByID() error = %v", err)
+	}
+	banner.Content = content
+	if err := fx.service.UpdateBanner(ctx, banner); err != nil {
+		t.Fatalf("UpdateBanner() error = %v", err)
+	}
+
+	draft := pendingVersion(t, fx, ctx, bannerID)
+	if err := fx.service.SubmitBannerVersion(ctx, bannerID, draft); err != nil {
+		t.Fatalf("SubmitBannerVersion() error = %v", err)
+	}
+	if err := fx.service.ApproveBannerVersion(ctx, bannerID, draft); err != nil {
+		t.Fatalf("ApproveBannerVersion() error = %v", err)
+	}
+}
+
+func TestGetBannerVersionsNewestFirst(t *testing.T) {
+	fx := newFixture(t)
+	ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+	banner := fx.createBanner(t, ctx, 1, []int64{1}, map[string]any{"title": "a"})
+	fx.publish(t, ctx, banner.BannerID, map[string]any{"title": "b"})
+	fx.publish(t, ctx, banner.BannerID, map[string]any{"title": "c"})
+
+	versions, err := fx.service.GetBannerVersions(ctx, banner.BannerID)
+	if err != nil {
+		t.Fatalf("GetBannerVersions() error = %v", err)
+	}
+
+	var titles []any
+	for _, v := range versions {
+		titles = append(titles, v.Content.(map[string]any)["title"])
+	}
+	if want := []any{"c", "b", "a"}; !reflect.DeepEqual(titles, want) {
+		t.Fatalf("versions = %v, want %v", titles, want)
+	}
+	if !versions[0].Active || versions[1].Active || versions[2].Active {
+		t.Errorf("active flags = %t, %t, %t, want only the newest", versions[0].Active, versions[1].Active, versions[2].Active)
+	}
+}
+
+func TestActivateBannerVersionRollsBack(t *testing.T) {
+	fx := newFixture(t)
+	ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+	banner := fx.createBanner(t, ctx, 1, []int64{1}, map[string]any{"title": "a"})
+	first := firstVersion(t, fx, ctx, banner.BannerID)
+	fx.publish(t, ctx, banner.BannerID, map[string]any{"title": "b"})
+
+	before, _ := fx.store.GetBannerByID(ctx, banner.BannerID)
+
+	restored, err := fx.service.ActivateBannerVersion(ctx, banner.BannerID, first)
+	if err != nil {
+		t.Fatalf("ActivateBannerVersion() error = %v", err)
+	}
+	if restored == first {
+		t.Fatalf("rollback reused revision %d, want a new one", first)
+	}
+
+	after, _ := fx.store.GetBannerByID(ctx, banner.BannerID)
+	if want := (map[string]any{"title": "a"}); !reflect.DeepEqual(after.Content, want) {
+		t.Errorf("content after rollback = %v, want %v", after.Content, want)
+	}
+	if after.Revision <= before.Revision {
+		t.Errorf("revision after rollback = %d, want more than %d", after.Revision, before.Revision)
+	}
+
+	// History is kept: the rolled back revision is still there.
+	versions, err := fx.service.GetBannerVersions(ctx, banner.BannerID)
+	if err != nil {
+		t.Fatalf("GetBannerVersions() error = %v", err)
+	}
+	if len(versions) != 3 || versions[0].VersionID != restored || !versions[0].Active {
+		t.Errorf("versions = %+v, want the restored revision first and active, of 3", versions)
+	}
+}
+
+func TestActivateUnknownBannerVersion(t *testing.T) {
+	fx := newFixture(t)
+	ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+	banner := fx.createBanner(t, ctx, 1, []int64{1}, map[string]any{"title": "a"})
+	other := fx.createBanner(t, ctx, 2, []int64{1}, map[string]any{"title": "x"})
+
+	tests := []struct {
+		name      string
+		bannerID  int64
+		versionID int64
+		wantErr   error
+	}{
+		{"unknown version", banner.BannerID, 999, domain.ErrBannerVersionNotFound},
+		{"version of another banner", banner.BannerID, firstVersion(t, fx, ctx, other.BannerID), domain.ErrBannerVersionNotFound},
+		{"unknown banner", 999, 1, domain.ErrBannerNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The handler answers both errors with 404.
+			if _, err := fx.service.ActivateBannerVersion(ctx, tt.bannerID, tt.versionID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ActivateBannerVersion() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if b, _ := fx.store.GetBannerByID(ctx, banner.BannerID); !reflect.DeepEqual(b.Content, map[string]any{"title": "a"}) {
+		t.Errorf("content after failed rollbacks = %v, want it unchanged", b.Content)
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+func TestDiffBannerVersions(t *testing.T) {
+	fx := newFixture(t)
+	ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+	banner := fx.createBanner(t, ctx, 1, []int64{1, 2}, map[string]any{"title": "old", "url": "http://a"})
+
+	newID, err := fx.service.ActivateBannerVersion(ctx, banner.BannerID, firstVersion(t, fx, ctx, banner.BannerID))
+	if err != nil {
+		t.Fatalf("ActivateBannerVersion() error = %v", err)
+	}
+
+	banner, _ = fx.store.GetBannerByID(ctx, banner.BannerID)
+	banner.Content = map[string]any{"title": "new"}
+	if err := fx.service.UpdateBanner(ctx, banner); err != nil {
+		t.Fatalf("UpdateBanner() error = %v", err)
+	}
+	draft := pendingVersion(t, fx, ctx, banner.BannerID)
+
+	diff, err := fx.service.DiffBannerVersions(ctx, banner.BannerID, draft, 0)
+	if err != nil {
+		t.Fatalf("DiffBannerVersions() error = %v", err)
+	}
+
+	if diff.From != draft || diff.To != newID {
+		t.Errorf("DiffBannerVersions() compared %d to %d, want %d to the active %d", diff.From, diff.To, draft, newID)
+	}
+
+	patch, _ := json.Marshal(diff.Patch)
+	want := `[{"op":"replace","path":"/content/title","value":"old"},{"op":"add","path":"/content/url","value":"http://a"}]`
+	if string(patch) != want {
+		t.Errorf("patch = %s, want %s", patch, want)
+	}
+
+	wantSummary := []string{`changed /content/title from "new" to "old"`, `added /content/url = "http://a"`}
+	if !reflect.DeepEqual(diff.Summary, wantSummary) {
+		t.Errorf("summary = %q, want %q", diff.Summary, wantSummary)
+	}
+}
+
+func TestDiffBannerVersionsUnknownVersion(t *testing.T) {
+	fx := newFixture(t)
+	ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+	banner := fx.createBanner(t, ctx, 1, []int64{1}, map[string]any{"title": "a"})
+
+	_, err := fx.service.DiffBannerVersions(ctx, banner.BannerID, 999, 0)
+	if !errors.Is(err, domain.ErrBannerVersionNotFound) {
+		t.Errorf("DiffBannerVersions() error = %v, want %v", err, domain.ErrBannerVersionNotFound)
+	}
+}
+
+// firstVersion returns the oldest revision of a banner.
+func firstVersion(t *testing.T, fx *fixture, ctx context.Context, bannerID int64) int64 {
+	t.Helper()
+
+	versions, err := fx.store.GetBannerVersions(ctx, bannerID)
+	if err != nil || len(versions) == 0 {
+		t.Fatalf("GetBannerVersions() = %v, %v", versions, err)
+	}
+	return versions[len(versions)-1].VersionID
+}
+
+// pendingVersion returns the draft or in-review revision of a banner.
+func pendingVersion(t *testing.T, fx *fixture, ctx context.Context, bannerID int64) int64 {
+	t.Helper()
+
+	versions, err := fx.store.GetBannerVersions(ctx, bannerID)
+	if err != nil {
+		t.Fatalf("GetBannerVersions() error = %v", err)
+	}
+	for _, v := range versions {
+		if v.Status != domain.VersionPublished {
+			return v.VersionID
+		}
+	}
+	t.Fatalf("banner %d has no pending revision", bannerID)
+	return 0
+}
@@ -0,0 +1,806 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/cache"
+	"github.com/panzerhomer/banner/internal/config"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+var errInjected = errors.New("injected failure")
+
+// fakeState is everything fakeStore keeps, copied as a whole to roll a
+// transaction back.
+type fakeState struct {
+	banners     map[int64]domain.Banner
+	versions    map[int64]domain.BannerVersion
+	transitions map[int64][]domain.BannerTransition
+	keys        map[int64]fakeKey
+	audit       []fakeAuditEntry
+	lastID      int64
+	clock       time.Time
+}
+
+type fakeKey struct {
+	key  domain.APIKey
+	hash string
+}
+
+type fakeAuditEntry struct {
+	tenant string
+	entry  domain.AuditEntry
+}
+
+func (s fakeState) clone() fakeState {
+	c := s
+	c.banners = make(map[int64]domain.Banner, len(s.banners))
+	for id, b := range s.banners {
+		c.banners[id] = b
+	}
+	c.versions = make(map[int64]domain.BannerVersion, len(s.versions))
+	for id, v := range s.versions {
+		c.versions[id] = v
+	}
+	c.transitions = make(map[int64][]domain.BannerTransition, len(s.transitions))
+	for id, t := range s.transitions {
+		c.transitions[id] = append([]domain.BannerTransition(nil), t...)
+	}
+	c.keys = make(map[int64]fakeKey, len(s.keys))
+	for id, k := range s.keys {
+		c.keys[id] = k
+	}
+	c.audit = append([]fakeAuditEntry(nil), s.audit...)
+	return c
+}
+
+// fakeStore is an in-memory BannerRepository, APIKeyRepository and
+// AuditRepository. Like the Postgres repositories it scopes every call to
+// the tenant of the context, and WithinTx rolls back everything fn wrote
+// if it fails. Setting fail[method] makes that method return the error.
+type fakeStore struct {
+	mu    sync.Mutex
+	state fakeState
+
+	fail   map[string]error
+	prunes int
+	// hang makes GetBanner wait until its context is done.
+	hang bool
+	// gate, if set, makes GetBanner wait until it is closed.
+	gate chan struct{}
+	// bannerLookups counts the calls of GetBanner.
+	bannerLookups int
+	// hashLookups counts the keys looked up by hash.
+	hashLookups int
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		state: fakeState{
+			banners:     make(map[int64]domain.Banner),
+			versions:    make(map[int64]domain.BannerVersion),
+			transitions: make(map[int64][]domain.BannerTransition),
+			keys:        make(map[int64]fakeKey),
+			clock:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		fail: make(map[string]error),
+	}
+}
+
+func (f *fakeStore) failing(method string) error {
+	return f.fail[method]
+}
+
+// now is a clock that moves on with every write, so revisions are ordered.
+func (f *fakeStore) now() time.Time {
+	f.state.clock = f.state.clock.Add(time.Second)
+	return f.state.clock
+}
+
+func (f *fakeStore) nextID() int64 {
+	f.state.lastID++
+	return f.state.lastID
+}
+
+func (f *fakeStore) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	f.mu.Lock()
+	saved := f.state.clone()
+	f.mu.Unlock()
+
+	if err := fn(ctx); err != nil {
+		f.mu.Lock()
+		f.state = saved
+		f.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// banner returns a banner of the tenant of ctx.
+func (f *fakeStore) banner(ctx context.Context, bannerID int64) (domain.Banner, bool) {
+	b, ok := f.state.banners[bannerID]
+	if !ok || b.Tenant != tenant.FromContext(ctx) {
+		return domain.Banner{}, false
+	}
+	return b, true
+}
+
+// published fills in the content of the newest published revision.
+func (f *fakeStore) published(b domain.Banner) (domain.Banner, bool) {
+	var latest *domain.BannerVersion
+	for _, v := range f.state.versions {
+		v := v
+		if v.BannerID != b.BannerID || v.Status != domain.VersionPublished {
+			continue
+		}
+		if latest == nil || v.UpdatedAt.After(latest.UpdatedAt) || (v.UpdatedAt.Equal(latest.UpdatedAt) && v.VersionID > latest.VersionID) {
+			latest = &v
+		}
+	}
+	if latest == nil {
+		return b, false
+	}
+
+	b.Content = latest.Content
+	b.CreatedAt = latest.CreatedAt
+	b.UpdatedAt = latest.UpdatedAt
+	return b, true
+}
+
+func (f *fakeStore) addVersion(b domain.Banner, content any, status string) int64 {
+	now := f.now()
+	id := f.nextID()
+	f.state.versions[id] = domain.BannerVersion{
+		VersionID: id,
+		BannerID:  b.BannerID,
+		TagIds:    b.TagIds,
+		FeatureID: b.FeatureID,
+		Content:   content,
+		IsActive:  b.IsActive,
+		StartAt:   b.StartAt,
+		EndAt:     b.EndAt,
+		Status:    status,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return id
+}
+
+func (f *fakeStore) InsertBanner(ctx context.Context, banner domain.Banner) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failing("InsertBanner"); err != nil {
+		return -1, err
+	}
+
+	banner.Tenant = tenant.FromContext(ctx)
+	for _, b := range f.state.banners {
+		if b.Tenant == banner.Tenant && b.FeatureID == banner.FeatureID && equalTags(b.TagIds, banner.TagIds) {
+			return -1, errors.New("duplicate feature and tags")
+		}
+	}
+
+	banner.BannerID = f.nextID()
+	banner.Revision = 1
+	content := banner.Content
+	banner.Content = nil
+	f.state.banners[banner.BannerID] = banner
+	f.addVersion(banner, content, domain.VersionPublished)
+
+	return banner.BannerID, nil
+}
+
+func (f *fakeStore) GetBanners(ctx context.Context, tagIDs []int64, featureID int64, limit int64, offset int64) ([]domain.Banner, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var banners []domain.Banner
+	for _, id := range f.sortedBannerIDs() {
+		b := f.state.banners[id]
+		if b.Tenant != tenant.FromContext(ctx) || b.FeatureID != featureID || !containsTags(b.TagIds, tagIDs) {
+			continue
+		}
+		if b, ok := f.published(b); ok {
+			banners = append(banners, b)
+		}
+	}
+
+	if offset >= int64(len(banners)) {
+		return nil, nil
+	}
+	banners = banners[offset:]
+	if limit > 0 && limit < int64(len(banners)) {
+		banners = banners[:limit]
+	}
+	return banners, nil
+}
+
+func (f *fakeStore) GetBanner(ctx context.Context, tagIDs []int64, featureID int64, isAdmin bool) ([]domain.Banner, error) {
+	f.mu.Lock()
+	f.bannerLookups++
+	gate := f.gate
+	f.mu.Unlock()
+
+	if f.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if gate != nil {
+		<-gate
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failing("GetBanner"); err != nil {
+		return nil, err
+	}
+
+	var banners []domain.Banner
+	for _, id := range f.sortedBannerIDs() {
+		b := f.state.banners[id]
+		if b.Tenant != tenant.FromContext(ctx) || b.FeatureID != featureID || !equalTags(b.TagIds, tagIDs) {
+			continue
+		}
+		if !isAdmin && !b.LiveAt(time.Now()) {
+			continue
+		}
+		if b, ok := f.published(b); ok {
+			banners = append(banners, b)
+		}
+	}
+	return banners, nil
+}
+
+func (f *fakeStore) UpdateBannerById(ctx context.Context, banner domain.Banner) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failing("UpdateBannerById"); err != nil {
+		return err
+	}
+
+	old, ok := f.banner(ctx, banner.BannerID)
+	if !ok {
+		return domain.ErrBannerNotFound
+	}
+	if old.Revision != banner.Revision {
+		return domain.ErrBannerRevisionStale
+	}
+
+	old.Revision++
+	f.state.banners[old.BannerID] = old
+
+	for id, v := range f.state.versions {
+		if v.BannerID == old.BannerID && (v.Status == domain.VersionDraft || v.Status == domain.VersionInReview) {
+			v.Content = banner.Content
+			v.FeatureID, v.TagIds, v.IsActive = banner.FeatureID, banner.TagIds, banner.IsActive
+			v.StartAt, v.EndAt = banner.StartAt, banner.EndAt
+			v.Status = domain.VersionDraft
+			v.UpdatedAt = f.now()
+			f.state.versions[id] = v
+			return nil
+		}
+	}
+	f.addVersion(banner, banner.Content, domain.VersionDraft)
+
+	return nil
+}
+
+func (f *fakeStore) DeleteBannerById(ctx context.Context, bannerID int64, revision int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failing("DeleteBannerById"); err != nil {
+		return err
+	}
+
+	old, ok := f.banner(ctx, bannerID)
+	if !ok {
+		return domain.ErrBannerNotFound
+	}
+	if old.Revision != revision {
+		return domain.ErrBannerRevisionStale
+	}
+
+	delete(f.state.banners, bannerID)
+	delete(f.state.transitions, bannerID)
+	for id, v := range f.state.versions {
+		if v.BannerID == bannerID {
+			delete(f.state.versions, id)
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeStore) GetBannerByID(ctx context.Context, bannerID int64) (domain.Banner, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, ok := f.banner(ctx, bannerID)
+	if !ok {
+		return domain.Banner{}, domain.ErrBannerNotFound
+	}
+	if b, ok = f.published(b); !ok {
+		return domain.Banner{}, domain.ErrBannerNotFound
+	}
+	return b, nil
+}
+
+func (f *fakeStore) GetBannerVersions(ctx context.Context, bannerID int64) ([]domain.BannerVersion, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.banner(ctx, bannerID); !ok {
+		return nil, nil
+	}
+
+	var versions []domain.BannerVersion
+	for _, v := range f.state.versions {
+		if v.BannerID == bannerID {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if !versions[i].UpdatedAt.Equal(versions[j].UpdatedAt) {
+			return versions[i].UpdatedAt.After(versions[j].UpdatedAt)
+		}
+		return versions[i].VersionID > versions[j].VersionID
+	})
+	for i := range versions {
+		if versions[i].Status == domain.VersionPublished {
+			versions[i].Active = true
+			break
+		}
+	}
+
+	return versions, nil
+}
+
+func (f *fakeStore) GetBannerVersion(ctx context.Context, bannerID int64, versionID int64) (domain.BannerVersion, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.banner(ctx, bannerID); !ok {
+		return domain.BannerVersion{}, domain.ErrBannerVersionNotFound
+	}
+	v, ok := f.state.versions[versionID]
+	if !ok || v.BannerID != bannerID {
+		return domain.BannerVersion{}, domain.ErrBannerVersionNotFound
+	}
+	return v, nil
+}
+
+func (f *fakeStore) InsertBannerVersion(ctx context.Context, bannerID int64, content any) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failing("InsertBannerVersion"); err != nil {
+		return -1, err
+	}
+
+	b, ok := f.banner(ctx, bannerID)
+	if !ok {
+		return -1, domain.ErrBannerNotFound
+	}
+
+	id := f.addVersion(b, content, domain.VersionPublished)
+	b.Revision++
+	f.state.banners[bannerID] = b
+
+	return id, nil
+}
+
+func (f *fakeStore) PruneBannerVersions(ctx context.Context, bannerID int64, keep int, before *time.Time) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.prunes++
+	if err := f.failing("PruneBannerVersions"); err != nil {
+		return 0, err
+	}
+	if _, ok := f.banner(ctx, bannerID); !ok {
+		return 0, nil
+	}
+
+	var published []domain.BannerVersion
+	for _, v := range f.state.versions {
+		if v.BannerID == bannerID && v.Status == domain.VersionPublished {
+			published = append(published, v)
+		}
+	}
+	sort.Slice(published, func(i, j int) bool {
+		if !published[i].UpdatedAt.Equal(published[j].UpdatedAt) {
+			return published[i].UpdatedAt.After(published[j].UpdatedAt)
+		}
+		return published[i].VersionID > published[j].VersionID
+	})
+
+	var pruned int64
+	for i, v := range published {
+		if i == 0 {
+			continue
+		}
+		if (keep > 0 && i+1 > keep) || (before != nil && v.UpdatedAt.Before(*before)) {
+			delete(f.state.versions, v.VersionID)
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
+
+func (f *fakeStore) SetBannerVersionStatus(ctx context.Context, bannerID int64, versionID int64, from string, to string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failing("SetBannerVersionStatus"); err != nil {
+		return err
+	}
+
+	b, ok := f.banner(ctx, bannerID)
+	if !ok {
+		return domain.ErrBannerVersionConflict
+	}
+	v, ok := f.state.versions[versionID]
+	if !ok || v.BannerID != bannerID || v.Status != from {
+		return domain.ErrBannerVersionConflict
+	}
+
+	v.Status = to
+	v.UpdatedAt = f.now()
+	f.state.versions[versionID] = v
+
+	if to == domain.VersionPublished {
+		b.FeatureID, b.TagIds, b.IsActive = v.FeatureID, v.TagIds, v.IsActive
+		b.StartAt, b.EndAt = v.StartAt, v.EndAt
+		b.Revision++
+		f.state.banners[bannerID] = b
+	}
+
+	return nil
+}
+
+func (f *fakeStore) ScheduleBannerTransitions(ctx context.Context, banner domain.Banner) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failing("ScheduleBannerTransitions"); err != nil {
+		return err
+	}
+	if _, ok := f.banner(ctx, banner.BannerID); !ok {
+		return nil
+	}
+
+	var transitions []domain.BannerTransition
+	now := time.Now()
+	if banner.StartAt != nil && banner.StartAt.After(now) {
+		transitions = append(transitions, domain.BannerTransition{BannerID: banner.BannerID, Action: domain.TransitionPublish, RunAt: *banner.StartAt})
+	}
+	if banner.EndAt != nil && banner.EndAt.After(now) {
+		transitions = append(transitions, domain.BannerTransition{BannerID: banner.BannerID, Action: domain.TransitionExpire, RunAt: *banner.EndAt})
+	}
+	f.state.transitions[banner.BannerID] = transitions
+
+	return nil
+}
+
+func (f *fakeStore) GetLiveBanners(ctx context.Context) ([]domain.Banner, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var banners []domain.Banner
+	for _, id := range f.sortedBannerIDs() {
+		b := f.state.banners[id]
+		if !b.LiveAt(time.Now()) {
+			continue
+		}
+		if b, ok := f.published(b); ok {
+			banners = append(banners, b)
+		}
+	}
+	return banners, nil
+}
+
+func (f *fakeStore) InsertAPIKey(ctx context.Context, key domain.APIKey, hash string) (domain.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failing("InsertAPIKey"); err != nil {
+		return domain.APIKey{}, err
+	}
+
+	key.ID = f.nextID()
+	key.Tenant = tenant.FromContext(ctx)
+	key.CreatedAt = f.now()
+	f.state.keys[key.ID] = fakeKey{key: key, hash: hash}
+
+	return key, nil
+}
+
+func (f *fakeStore) GetAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var keys []domain.APIKey
+	for _, k := range f.state.keys {
+		if k.key.Tenant == tenant.FromContext(ctx) {
+			keys = append(keys, k.key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	return keys, nil
+}
+
+func (f *fakeStore) GetAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.hashLookups++
+	for _, k := range f.state.keys {
+		if k.hash == hash {
+			return k.key, nil
+		}
+	}
+	return domain.APIKey{}, domain.ErrAPIKeyNotFound
+}
+
+func (f *fakeStore) RotateAPIKey(ctx context.Context, id int64, prefix string, hash string) (domain.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	k, ok := f.state.keys[id]
+	if !ok || k.key.Tenant != tenant.FromContext(ctx) || k.key.RevokedAt != nil {
+		return domain.APIKey{}, domain.ErrAPIKeyNotFound
+	}
+
+	now := f.now()
+	k.key.Prefix = prefix
+	k.key.RotatedAt = &now
+	k.hash = hash
+	f.state.keys[id] = k
+
+	return k.key, nil
+}
+
+func (f *fakeStore) RevokeAPIKey(ctx context.Context, id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	k, ok := f.state.keys[id]
+	if !ok || k.key.Tenant != tenant.FromContext(ctx) {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	if k.key.RevokedAt == nil {
+		now := f.now()
+		k.key.RevokedAt = &now
+		f.state.keys[id] = k
+	}
+
+	return nil
+}
+
+func (f *fakeStore) TouchAPIKeys(ctx context.Context, uses map[int64]time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failing("TouchAPIKeys"); err != nil {
+		return err
+	}
+
+	for id, t := range uses {
+		t := t
+		k, ok := f.state.keys[id]
+		if ok && (k.key.LastUsedAt == nil || k.key.LastUsedAt.Before(t)) {
+			k.key.LastUsedAt = &t
+			f.state.keys[id] = k
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeStore) InsertAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failing("InsertAuditEntry"); err != nil {
+		return err
+	}
+
+	entry.ID = f.nextID()
+	entry.CreatedAt = f.now()
+	f.state.audit = append(f.state.audit, fakeAuditEntry{tenant: tenant.FromContext(ctx), entry: entry})
+
+	return nil
+}
+
+func (f *fakeStore) GetAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var entries []domain.AuditEntry
+	for i := len(f.state.audit) - 1; i >= 0; i-- {
+		e := f.state.audit[i]
+		switch {
+		case e.tenant != tenant.FromContext(ctx):
+		case filter.Actor != "" && e.entry.Actor != filter.Actor:
+		case filter.BannerID != 0 && (e.entry.BannerID == nil || *e.entry.BannerID != filter.BannerID):
+		case filter.FeatureID != 0 && (e.entry.FeatureID == nil || *e.entry.FeatureID != filter.FeatureID):
+		case filter.Cursor != 0 && e.entry.ID >= filter.Cursor:
+		case filter.Features != nil && !inRanges(e.entry.FeatureID, filter.Features):
+		default:
+			entries = append(entries, e.entry)
+		}
+		if int64(len(entries)) == filter.Limit {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+func (f *fakeStore) sortedBannerIDs() []int64 {
+	ids := make([]int64, 0, len(f.state.banners))
+	for id := range f.state.banners {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func inRanges(featureID *int64, ranges []domain.FeatureRange) bool {
+	if featureID == nil {
+		return false
+	}
+	for _, r := range ranges {
+		if *featureID >= r.From && *featureID <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+func equalTags(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsTags(tags, wanted []int64) bool {
+	for _, w := range wanted {
+		found := false
+		for _, t := range tags {
+			if t == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// testConfig is a configuration with the caches and retention the tests
+// rely on.
+func testConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Cache.TTL = time.Minute
+	cfg.Cache.StaleTTL = time.Hour
+	cfg.Cache.NegativeTTL = time.Minute
+	cfg.Cache.LookupTimeout = time.Second
+	cfg.Cache.KeyPrefix = "test"
+	cfg.Cache.KeyVersion = 1
+	cfg.Cache.WarmUp.Concurrency = 2
+	cfg.Cache.WarmUp.Budget = time.Second
+	cfg.Versions.Retention = domain.RetentionPolicy{Keep: 10}
+	return cfg
+}
+
+// fixture is a banner service over a fake store and an in-memory cache.
+type fixture struct {
+	store   *fakeStore
+	cache   *cache.LRU
+	keys    cache.KeyScheme
+	policy  *auth.Policy
+	service *bannerService
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	cfg := testConfig()
+	policy, err := auth.NewPolicy(cfg)
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+
+	store := newFakeStore()
+	keys := cache.NewKeyScheme(cfg)
+	lru := cache.NewLRU(100, time.Hour, keys)
+
+	return &fixture{
+		store:   store,
+		cache:   lru,
+		keys:    keys,
+		policy:  policy,
+		service: NewBannerService(store, lru, policy, NewAuditService(store, policy), cfg),
+	}
+}
+
+// limitedPolicy is a policy whose "limited" role has the permissions for
+// the given features only.
+func limitedPolicy(t *testing.T, features string, permissions ...auth.Permission) *auth.Policy {
+	t.Helper()
+
+	cfg := testConfig()
+	cfg.RBAC.Roles = map[string][]config.Grant{}
+	for _, p := range permissions {
+		cfg.RBAC.Roles["limited"] = append(cfg.RBAC.Roles["limited"], config.Grant{Permission: string(p), Features: []string{features}})
+	}
+
+	policy, err := auth.NewPolicy(cfg)
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+	return policy
+}
+
+// as returns a context authenticated as subject with role in tenantID.
+func as(subject string, role string, tenantID string) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{Subject: subject, Role: role, Tenant: tenantID})
+}
+
+// createBanner creates a banner and fails the test if that does not work.
+func (fx *fixture) createBanner(t *testing.T, ctx context.Context, featureID int64, tagIDs []int64, content any) domain.Banner {
+	t.Helper()
+
+	banner := domain.Banner{FeatureID: featureID, TagIds: tagIDs, Content: content, IsActive: true}
+	id, err := fx.service.CreateBanner(ctx, banner)
+	if err != nil {
+		t.Fatalf("CreateBanner() error = %v", err)
+	}
+
+	created, err := fx.store.GetBannerByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetBannerByID(%d) error = %v", id, err)
+	}
+	return created
+}
+
+// seed puts an entry for the tenant, feature and tags into the cache.
+func (fx *fixture) seed(t *testing.T, tenantID string, tagIDs []int64, featureID int64) {
+	t.Helper()
+
+	entry := cache.NewNotFoundEntry(tenantID, tagIDs, featureID, time.Now(), time.Hour)
+	if err := fx.cache.SaveBanner(entry); err != nil {
+		t.Fatalf("SaveBanner() error = %v", err)
+	}
+}
+
+// cached reports whether the cache holds an entry for the tenant, feature
+// and tags.
+func (fx *fixture) cached(tenantID string, tagIDs []int64, featureID int64) bool {
+	entry, err := fx.cache.GetBanner(tenantID, tagIDs, featureID)
+	return err == nil && entry != nil
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/cache"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+// seedStale caches banner with content as a copy whose fresh window is over.
+func (fx *fixture) seedStale(t *testing.T, banner domain.Banner, content any) {
+	t.Helper()
+
+	banner.Content = content
+	entry, ok := cache.NewEntry(banner, time.Now().Add(-2*time.Minute), time.Minute, time.Hour)
+	if !ok {
+		t.Fatal("NewEntry() refused a live banner")
+	}
+	if err := fx.cache.SaveBanner(entry); err != nil {
+		t.Fatalf("SaveBanner() error = %v", err)
+	}
+}
+
+func TestGetBannerServesFreshCopies(t *testing.T) {
+	fx := newFixture(t)
+	admin := as("alice", auth.RoleAdmin, tenant.Default)
+	user := as("bob", auth.RoleUser, tenant.Default)
+
+	fx.createBanner(t, admin, 1, []int64{1}, map[string]any{"title": "a"})
+
+	for i := 0; i < 3; i++ {
+		lookup, err := fx.service.GetBanner(user, []int64{1}, 1, false)
+		if err != nil {
+			t.Fatalf("GetBanner() error = %v", err)
+		}
+		if lookup.Stale {
+			t.Errorf("lookup %d is stale, want fresh", i)
+		}
+		if lookup.MaxAge <= 0 || lookup.MaxAge > time.Minute {
+			t.Errorf("lookup %d max age = %s, want within the fresh window", i, lookup.MaxAge)
+		}
+	}
+
+	if queries := fx.service.queries.Load(); queries != 1 {
+		t.Errorf("database queries = %d, want 1", queries)
+	}
+}
+
+func TestGetBannerRefreshesStaleCopies(t *testing.T) {
+	fx := newFixture(t)
+	admin := as("alice", auth.RoleAdmin, tenant.Default)
+	user := as("bob", auth.RoleUser, tenant.Default)
+
+	banner := fx.createBanner(t, admin, 1, []int64{1}, map[string]any{"title": "new"})
+	fx.seedStale(t, banner, map[string]any{"title": "old"})
+
+	lookup, err := fx.service.GetBanner(user, []int64{1}, 1, false)
+	if err != nil {
+		t.Fatalf("GetBanner() error = %v", err)
+	}
+	if !lookup.Stale || lookup.MaxAge != 0 {
+		t.Errorf("lookup = stale %t, max age %s, want stale without max age", lookup.Stale, lookup.MaxAge)
+	}
+	if want := (map[string]any{"title": "old"}); !reflect.DeepEqual(lookup.Banners[0].Content, want) {
+		t.Errorf("content = %v, want the cached %v", lookup.Banners[0].Content, want)
+	}
+
+	// The copy is refreshed in the background.
+	deadline := time.Now().Add(time.Second)
+	for {
+		entry, err := fx.cache.GetBanner(tenant.Default, []int64{1}, 1)
+		if err == nil && entry.Fresh(time.Now()) {
+			if want := (map[string]any{"title": "new"}); !reflect.DeepEqual(entry.Banner.Content, want) {
+				t.Errorf("refreshed content = %v, want %v", entry.Banner.Content, want)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stale copy was not refreshed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	lookup, err = fx.service.GetBanner(user, []int64{1}, 1, false)
+	if err != nil {
+		t.Fatalf("GetBanner() error = %v", err)
+	}
+	if lookup.Stale {
+		t.Error("lookup after the refresh is stale, want fresh")
+	}
+}
+
+func TestGetBannerWhileDatabaseIsDown(t *testing.T) {
+	fx := newFixture(t)
+	admin := as("alice", auth.RoleAdmin, tenant.Default)
+	user := as("bob", auth.RoleUser, tenant.Default)
+
+	banner := fx.createBanner(t, admin, 1, []int64{1}, map[string]any{"title": "new"})
+	fx.seedStale(t, banner, map[string]any{"title": "old"})
+
+	fx.store.mu.Lock()
+	fx.store.fail["GetBanner"] = errInjected
+	fx.store.mu.Unlock()
+
+	tests := []struct {
+		name        string
+		lastVersion bool
+	}{
+		{"stale copy", false},
+		// The refresh above failed, the copy is still stale.
+		{"stale copy again", false},
+		{"last revision", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup, err := fx.service.GetBanner(user, []int64{1}, 1, tt.lastVersion)
+			if err != nil {
+				t.Fatalf("GetBanner() error = %v", err)
+			}
+			if !lookup.Stale {
+				t.Error("lookup is not marked stale")
+			}
+			if want := (map[string]any{"title": "old"}); !reflect.DeepEqual(lookup.Banners[0].Content, want) {
+				t.Errorf("content = %v, want the cached %v", lookup.Banners[0].Content, want)
+			}
+		})
+	}
+}
+
+func TestGetBannerCachesMisses(t *testing.T) {
+	fx := newFixture(t)
+	admin := as("alice", auth.RoleAdmin, tenant.Default)
+	user := as("bob", auth.RoleUser, tenant.Default)
+
+	if _, err := fx.service.GetBanner(user, []int64{1}, 1, false); !errors.Is(err, domain.ErrBannerNotFound) {
+		t.Fatalf("GetBanner() error = %v, want %v", err, domain.ErrBannerNotFound)
+	}
+
+	entry, err := fx.cache.GetBanner(tenant.Default, []int64{1}, 1)
+	if err != nil {
+		t.Fatalf("miss not cached: %v", err)
+	}
+	if !entry.NotFound {
+		t.Fatalf("cached entry = %+v, want a not-found entry", entry)
+	}
+	if ttl := time.Until(entry.ExpiresAt); ttl <= 0 || ttl > testConfig().Cache.NegativeTTL {
+		t.Errorf("not-found entry kept for %s, want the negative TTL %s", ttl, testConfig().Cache.NegativeTTL)
+	}
+
+	// Further misses are answered from the cache.
+	if _, err := fx.service.GetBanner(user, []int64{1}, 1, false); !errors.Is(err, domain.ErrBannerNotFound) {
+		t.Fatalf("GetBanner() error = %v, want %v", err, domain.ErrBannerNotFound)
+	}
+	if queries := fx.service.queries.Load(); queries != 1 {
+		t.Errorf("database queries = %d, want 1", queries)
+	}
+
+	fx.createBanner(t, admin, 1, []int64{1}, map[string]any{"title": "a"})
+
+	if _, err := fx.service.GetBanner(user, []int64{1}, 1, false); err != nil {
+		t.Errorf("GetBanner() after create error = %v, want the new banner", err)
+	}
+}
+
+func TestAdminsBypassCachedMisses(t *testing.T) {
+	fx := newFixture(t)
+	admin := as("alice", auth.RoleAdmin, tenant.Default)
+	user := as("bob", auth.RoleUser, tenant.Default)
+
+	// Users do not see inactive banners, admins do.
+	banner := domain.Banner{FeatureID: 1, TagIds: []int64{1}, Content: map[string]any{"title": "a"}}
+	if _, err := fx.service.CreateBanner(admin, banner); err != nil {
+		t.Fatalf("CreateBanner() error = %v", err)
+	}
+
+	if _, err := fx.service.GetBanner(user, []int64{1}, 1, false); !errors.Is(err, domain.ErrBannerNotFound) {
+		t.Fatalf("GetBanner() as user error = %v, want %v", err, domain.ErrBannerNotFound)
+	}
+	if entry, err := fx.cache.GetBanner(tenant.Default, []int64{1}, 1); err != nil || !entry.NotFound {
+		t.Fatalf("miss not cached: %v", err)
+	}
+
+	lookup, err := fx.service.GetBanner(admin, []int64{1}, 1, false)
+	if err != nil {
+		t.Fatalf("GetBanner() as admin error = %v", err)
+	}
+	if len(lookup.Banners) != 1 || lookup.Banners[0].IsActive {
+		t.Errorf("admin lookup = %+v, want the inactive banner", lookup.Banners)
+	}
+}
+
+func TestGetBannerCoalescesConcurrentMisses(t *testing.T) {
+	fx := newFixture(t)
+	admin := as("alice", auth.RoleAdmin, tenant.Default)
+	user := as("bob", auth.RoleUser, tenant.Default)
+
+	fx.createBanner(t, admin, 1, []int64{1}, map[string]any{"title": "a"})
+
+	gate := make(chan struct{})
+	fx.store.mu.Lock()
+	fx.store.gate = gate
+	fx.store.mu.Unlock()
+
+	const callers = 20
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			_, err := fx.service.GetBanner(user, []int64{1}, 1, false)
+			errs <- err
+		}()
+	}
+
+	// Hold the query until every caller has missed the cache and waits.
+	deadline := time.Now().Add(time.Second)
+	for fx.service.misses.Load() < callers {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d callers missed the cache", fx.service.misses.Load(), callers)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(gate)
+
+	for i := 0; i < callers; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("GetBanner() error = %v", err)
+		}
+	}
+
+	fx.store.mu.Lock()
+	lookups := fx.store.bannerLookups
+	fx.store.mu.Unlock()
+	if lookups != 1 {
+		t.Errorf("repository lookups = %d, want 1", lookups)
+	}
+
+	report := fx.service.CacheStats(admin)
+	if report.Lookups != callers || report.Queries != 1 || report.Coalesced != callers-1 {
+		t.Errorf("report = %d lookups, %d queries, %d coalesced, want %d, 1, %d", report.Lookups, report.Queries, report.Coalesced, callers, callers-1)
+	}
+}
+
+func TestGetBannerMaxAgeEndsWithTheBanner(t *testing.T) {
+	fx := newFixture(t)
+	admin := as("alice", auth.RoleAdmin, tenant.Default)
+	user := as("bob", auth.RoleUser, tenant.Default)
+
+	// The fresh window is a minute, the banner ends earlier.
+	endAt := time.Now().Add(20 * time.Second)
+	banner := domain.Banner{FeatureID: 1, TagIds: []int64{1}, Content: map[string]any{"title": "a"}, IsActive: true, EndAt: &endAt}
+	if _, err := fx.service.CreateBanner(admin, banner); err != nil {
+		t.Fatalf("CreateBanner() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		lastVersion bool
+		wantMax     time.Duration
+	}{
+		{"from the database", false, 20 * time.Second},
+		{"from the cache", false, 20 * time.Second},
+		// Clients asking for the last revision get nothing to reuse.
+		{"last revision", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup, err := fx.service.GetBanner(user, []int64{1}, 1, tt.lastVersion)
+			if err != nil {
+				t.Fatalf("GetBanner() error = %v", err)
+			}
+			if lookup.MaxAge > tt.wantMax || (tt.wantMax > 0 && lookup.MaxAge < tt.wantMax-time.Second) {
+				t.Errorf("max age = %s, want %s", lookup.MaxAge, tt.wantMax)
+			}
+		})
+	}
+}
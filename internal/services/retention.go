@@ -0,0 +1,33 @@
+package services
+
+import (
+	"github.com/panzerhomer/banner/internal/config"
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+// retentionPolicies resolves the revision retention policy of a banner:
+// a per-banner override wins over a per-feature one, which wins over the
+// global default.
+type retentionPolicies struct {
+	global   domain.RetentionPolicy
+	features map[int64]domain.RetentionPolicy
+	banners  map[int64]domain.RetentionPolicy
+}
+
+func newRetentionPolicies(cfg *config.Config) retentionPolicies {
+	return retentionPolicies{
+		global:   cfg.Versions.Retention,
+		features: cfg.Versions.Features,
+		banners:  cfg.Versions.Banners,
+	}
+}
+
+func (p retentionPolicies) For(banner domain.Banner) domain.RetentionPolicy {
+	if policy, ok := p.banners[banner.BannerID]; ok {
+		return policy
+	}
+	if policy, ok := p.features[banner.FeatureID]; ok {
+		return policy
+	}
+	return p.global
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/panzerhomer/banner/internal/config"
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+func TestRetentionPoliciesFor(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Versions.Retention = domain.RetentionPolicy{Keep: 10}
+	cfg.Versions.Features = map[int64]domain.RetentionPolicy{
+		7: {Days: 30},
+	}
+	cfg.Versions.Banners = map[int64]domain.RetentionPolicy{
+		42: {Keep: 2},
+		43: {},
+	}
+	policies := newRetentionPolicies(cfg)
+
+	tests := []struct {
+		name   string
+		banner domain.Banner
+		want   domain.RetentionPolicy
+	}{
+		{"global default", domain.Banner{BannerID: 1, FeatureID: 1}, domain.RetentionPolicy{Keep: 10}},
+		{"feature override", domain.Banner{BannerID: 1, FeatureID: 7}, domain.RetentionPolicy{Days: 30}},
+		{"banner override wins over feature", domain.Banner{BannerID: 42, FeatureID: 7}, domain.RetentionPolicy{Keep: 2}},
+		{"banner override to keep forever", domain.Banner{BannerID: 43, FeatureID: 7}, domain.RetentionPolicy{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policies.For(tt.banner); got != tt.want {
+				t.Errorf("For() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetentionPoliciesForWithoutOverrides(t *testing.T) {
+	policies := newRetentionPolicies(&config.Config{})
+
+	got := policies.For(domain.Banner{BannerID: 1, FeatureID: 1})
+	if !got.Forever() {
+		t.Errorf("For() = %+v, want a policy that keeps every revision", got)
+	}
+}
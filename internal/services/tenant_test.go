@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+// TestTenantIsolation checks that a principal never sees or changes what
+// belongs to another tenant, even with the same feature and tags.
+func TestTenantIsolation(t *testing.T) {
+	fx := newFixture(t)
+	shop := as("alice", auth.RoleAdmin, "shop")
+	news := as("bob", auth.RoleAdmin, "news")
+
+	shopBanner := fx.createBanner(t, shop, 1, []int64{1}, "shop")
+	newsBanner := fx.createBanner(t, news, 1, []int64{1}, "news")
+
+	// Equal feature and tags of two tenants are cached apart.
+	for _, tt := range []struct {
+		ctx  context.Context
+		want string
+	}{{shop, "shop"}, {news, "news"}, {shop, "shop"}} {
+		lookup, err := fx.service.GetBanner(tt.ctx, []int64{1}, 1, false)
+		if err != nil {
+			t.Fatalf("GetBanner() error = %v", err)
+		}
+		if got := lookup.Banners[0].Content; got != tt.want {
+			t.Errorf("GetBanner() content = %v, want %v", got, tt.want)
+		}
+	}
+
+	keys := NewAPIKeyService(fx.store, fx.policy, NewAuditService(fx.store, fx.policy), nil)
+	shopKey, err := keys.CreateAPIKey(shop, domain.APIKeyRequest{Name: "app"})
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+
+	id := shopBanner.BannerID
+	version := firstVersion(t, fx, shop, id)
+	notFound := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"update", func() error {
+			b := shopBanner
+			b.Content = "hijacked"
+			return fx.service.UpdateBanner(news, b)
+		}, domain.ErrBannerNotFound},
+		{"delete", func() error { return fx.service.DeleteBanner(news, id, shopBanner.Revision) }, domain.ErrBannerNotFound},
+		{"versions", func() error {
+			_, err := fx.service.GetBannerVersions(news, id)
+			return err
+		}, domain.ErrBannerNotFound},
+		{"diff", func() error {
+			_, err := fx.service.DiffBannerVersions(news, id, version, 0)
+			return err
+		}, domain.ErrBannerNotFound},
+		{"activate", func() error {
+			_, err := fx.service.ActivateBannerVersion(news, id, version)
+			return err
+		}, domain.ErrBannerNotFound},
+		{"approve", func() error { return fx.service.ApproveBannerVersion(news, id, version) }, domain.ErrBannerNotFound},
+		{"prune", func() error {
+			_, err := fx.service.PruneBannerVersions(news, id)
+			return err
+		}, domain.ErrBannerNotFound},
+		{"rotate key", func() error {
+			_, err := keys.RotateAPIKey(news, shopKey.ID)
+			return err
+		}, domain.ErrAPIKeyNotFound},
+		{"revoke key", func() error { return keys.RevokeAPIKey(news, shopKey.ID) }, domain.ErrAPIKeyNotFound},
+	}
+	for _, tt := range notFound {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Nothing of shop was touched.
+	if b, err := fx.store.GetBannerByID(shop, id); err != nil || b.Revision != shopBanner.Revision || b.Content != "shop" {
+		t.Errorf("shop banner = %+v, %v, want it unchanged", b, err)
+	}
+	if principal, err := keys.ResolveAPIKey(context.Background(), shopKey.Key); err != nil || principal.Tenant != "shop" {
+		t.Errorf("ResolveAPIKey() = %+v, %v, want a principal of shop", principal, err)
+	}
+
+	if list, err := keys.GetAPIKeys(news); err != nil || len(list) != 0 {
+		t.Errorf("GetAPIKeys() of news = %v, %v, want none", list, err)
+	}
+
+	page, err := NewAuditService(fx.store, fx.policy).GetAuditEntries(news, domain.AuditFilter{})
+	if err != nil {
+		t.Fatalf("GetAuditEntries() error = %v", err)
+	}
+	for _, e := range page.Entries {
+		if e.BannerID == nil || *e.BannerID != newsBanner.BannerID {
+			t.Errorf("news reads audit entry %+v of another tenant", e)
+		}
+	}
+	if len(page.Entries) == 0 {
+		t.Error("news reads no audit entries, want its own")
+	}
+}
+
+// TestTenantInvalidation checks that a write evicts the cache of its own
+// tenant only.
+func TestTenantInvalidation(t *testing.T) {
+	fx := newFixture(t)
+	shop := as("alice", auth.RoleAdmin, "shop")
+	news := as("bob", auth.RoleAdmin, "news")
+
+	shopBanner := fx.createBanner(t, shop, 1, []int64{1}, "shop")
+	fx.createBanner(t, news, 1, []int64{1}, "news")
+
+	fx.seed(t, "shop", []int64{1}, 1)
+	fx.seed(t, "news", []int64{1}, 1)
+
+	if err := fx.service.DeleteBanner(shop, shopBanner.BannerID, shopBanner.Revision); err != nil {
+		t.Fatalf("DeleteBanner() error = %v", err)
+	}
+
+	if fx.cached("shop", []int64{1}, 1) {
+		t.Error("shop entry still cached after delete")
+	}
+	if !fx.cached("news", []int64{1}, 1) {
+		t.Error("delete in shop evicted the entry of news")
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/domain"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+// TestWriteFailuresRollBack checks that a write failing half-way leaves
+// nothing behind: no data, no audit entry, and no cache eviction or prune,
+// which only run once the transaction is committed.
+func TestWriteFailuresRollBack(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		fail  string
+		write func(fx *fixture, ctx context.Context, banner domain.Banner) error
+	}{
+		{"create, scheduling fails", "ScheduleBannerTransitions", func(fx *fixture, ctx context.Context, banner domain.Banner) error {
+			_, err := fx.service.CreateBanner(ctx, domain.Banner{FeatureID: 1, TagIds: []int64{2}, StartAt: &later, Content: "b"})
+			return err
+		}},
+		{"create, audit fails", "InsertAuditEntry", func(fx *fixture, ctx context.Context, banner domain.Banner) error {
+			_, err := fx.service.CreateBanner(ctx, domain.Banner{FeatureID: 1, TagIds: []int64{2}, Content: "b"})
+			return err
+		}},
+		{"update, audit fails", "InsertAuditEntry", func(fx *fixture, ctx context.Context, banner domain.Banner) error {
+			banner.Content = "b"
+			return fx.service.UpdateBanner(ctx, banner)
+		}},
+		{"delete, audit fails", "InsertAuditEntry", func(fx *fixture, ctx context.Context, banner domain.Banner) error {
+			return fx.service.DeleteBanner(ctx, banner.BannerID, banner.Revision)
+		}},
+		{"activate, audit fails", "InsertAuditEntry", func(fx *fixture, ctx context.Context, banner domain.Banner) error {
+			_, err := fx.service.ActivateBannerVersion(ctx, banner.BannerID, firstVersion(t, fx, ctx, banner.BannerID))
+			return err
+		}},
+		{"approve, scheduling fails", "ScheduleBannerTransitions", func(fx *fixture, ctx context.Context, banner domain.Banner) error {
+			return fx.service.ApproveBannerVersion(ctx, banner.BannerID, pendingVersion(t, fx, ctx, banner.BannerID))
+		}},
+		{"approve, audit fails", "InsertAuditEntry", func(fx *fixture, ctx context.Context, banner domain.Banner) error {
+			return fx.service.ApproveBannerVersion(ctx, banner.BannerID, pendingVersion(t, fx, ctx, banner.BannerID))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fx := newFixture(t)
+			ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+			banner := fx.createBanner(t, ctx, 1, []int64{1}, "a")
+
+			// A moved draft under review, for the approvals.
+			edit := banner
+			edit.TagIds = []int64{2}
+			edit.EndAt = &later
+			if err := fx.service.UpdateBanner(ctx, edit); err != nil {
+				t.Fatalf("UpdateBanner() error = %v", err)
+			}
+			banner, _ = fx.store.GetBannerByID(ctx, banner.BannerID)
+			if err := fx.service.SubmitBannerVersion(ctx, banner.BannerID, pendingVersion(t, fx, ctx, banner.BannerID)); err != nil {
+				t.Fatalf("SubmitBannerVersion() error = %v", err)
+			}
+
+			fx.seed(t, tenant.Default, []int64{1}, 1)
+			fx.seed(t, tenant.Default, []int64{2}, 1)
+
+			before := fx.store.state.clone()
+			fx.store.prunes = 0
+			fx.store.fail[tt.fail] = errInjected
+
+			if err := tt.write(fx, ctx, banner); !errors.Is(err, errInjected) {
+				t.Fatalf("error = %v, want %v", err, errInjected)
+			}
+
+			after := fx.store.state
+			after.clock = before.clock
+			after.lastID = before.lastID
+			if !reflect.DeepEqual(after, before) {
+				t.Errorf("store changed by a failed write:\nbefore %+v\nafter  %+v", before, after)
+			}
+			if !fx.cached(tenant.Default, []int64{1}, 1) || !fx.cached(tenant.Default, []int64{2}, 1) {
+				t.Error("failed write evicted cache entries")
+			}
+			if fx.store.prunes != 0 {
+				t.Errorf("failed write pruned %d times", fx.store.prunes)
+			}
+		})
+	}
+}
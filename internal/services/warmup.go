@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/panzerhomer/banner/internal/cache"
+	"github.com/panzerhomer/banner/internal/domain"
+)
+
+// WarmUp loads every live banner into the cache using at most concurrency
+// parallel writes. It stops when the budget is spent and reports how far it
+// got; a partial warm-up is not an error.
+func (s *bannerService) WarmUp(ctx context.Context) (domain.WarmUpReport, error) {
+	started := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, s.warmUp.budget)
+	defer cancel()
+
+	banners, err := s.repo.GetLiveBanners(ctx)
+	if err != nil {
+		return domain.WarmUpReport{}, err
+	}
+
+	var cached, failed atomic.Int64
+
+	queue := make(chan domain.Banner)
+	var wg sync.WaitGroup
+
+	// Without a worker nothing would drain the queue.
+	concurrency := s.warmUp.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for banner := range queue {
+				entry, ok := cache.NewEntry(banner, time.Now(), s.freshTTL, s.staleTTL)
+				if !ok {
+					continue
+				}
+				if err := s.cache.SaveBanner(entry); err != nil {
+					log.Println("warm-up: cache save failed: ", err)
+					failed.Add(1)
+					continue
+				}
+				cached.Add(1)
+			}
+		}()
+	}
+
+	complete := true
+feed:
+	for _, banner := range banners {
+		select {
+		case queue <- banner:
+		case <-ctx.Done():
+			complete = false
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	report := domain.WarmUpReport{
+		Banners:  len(banners),
+		Cached:   cached.Load(),
+		Failed:   failed.Load(),
+		Duration: time.Since(started).String(),
+		Complete: complete,
+	}
+
+	log.Printf("warm-up: cached %d of %d banners in %s (complete: %t)", report.Cached, report.Banners, report.Duration, report.Complete)
+
+	return report, nil
+}
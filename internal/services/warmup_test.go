@@ -0,0 +1,26 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/panzerhomer/banner/internal/auth"
+	"github.com/panzerhomer/banner/internal/tenant"
+)
+
+func TestWarmUpWithoutConcurrency(t *testing.T) {
+	fx := newFixture(t)
+	ctx := as("alice", auth.RoleAdmin, tenant.Default)
+
+	fx.createBanner(t, ctx, 1, []int64{1}, map[string]any{"title": "a"})
+	fx.createBanner(t, ctx, 2, []int64{1}, map[string]any{"title": "b"})
+
+	fx.service.warmUp.concurrency = 0
+
+	report, err := fx.service.WarmUp(ctx)
+	if err != nil {
+		t.Fatalf("WarmUp() error = %v", err)
+	}
+	if !report.Complete || report.Cached != 2 {
+		t.Errorf("WarmUp() = %+v, want both banners cached", report)
+	}
+}
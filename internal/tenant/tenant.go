@@ -0,0 +1,32 @@
+package tenant
+
+import (
+	"context"
+	"regexp"
+)
+
+// Default is the tenant of callers that do not name one, so a deployment
+// serving a single product line needs no tenant setup at all.
+const Default = "default"
+
+// valid tenant IDs are safe to embed in cache keys and channel names.
+var valid = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+func Valid(id string) bool {
+	return valid.MatchString(id)
+}
+
+type tenantKey struct{}
+
+// With returns a context whose repository calls are scoped to tenant id.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext returns the tenant of ctx, Default if it names none.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(tenantKey{}).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}